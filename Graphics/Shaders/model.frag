@@ -3,6 +3,7 @@ in vec3 fragNormal;
 in vec3 fragVert;
 in vec3 fragWorldPos;
 in vec4 FragPosLightSpace;
+in vec2 fragTexCoord;
 
 flat in uint fragMatIndex;
 
@@ -11,6 +12,8 @@ uniform vec3 MaterialColors[6];
 
 uniform mat4 lightSpaceMatrix;
 uniform sampler2D ShadowMap;
+uniform sampler2D PartTexture;
+uniform bool Textured;
 
 uniform float shadowBias = 0.0006;
 
@@ -34,6 +37,9 @@ float ShadowCalc(){
 
 void main() {
     vec3 MaterialColor = MaterialColors[fragMatIndex];
+    if (Textured){
+        MaterialColor *= texture(PartTexture, fragTexCoord).rgb;
+    }
     //vec3 MaterialColor = vec3(1,0,0);
     vec3 lightDir   = normalize(lightpos - fragWorldPos);
     vec3 viewDir    = normalize(viewpos - fragWorldPos);
@@ -1,6 +1,7 @@
 #version 330
 in vec3 vert;
 in vec3 normal;
+in vec2 vertTexCoord;
 in uint material_index;
 in uint objID;
 
@@ -12,7 +13,7 @@ uniform mat4 MVP;
 
 uniform mat4 lightSpaceMatrix;
 
-uniform mat4 partMatricies[20];
+uniform samplerBuffer partMatricies; //a matrix for each part, one column per texel
 
 
 out vec2 fragTexCoord;
@@ -24,11 +25,13 @@ out vec4 FragPosLightSpace;
 
 flat out uint fragMatIndex;
 void main() {
-    //fragTexCoord = vertTexCoord;
+    fragTexCoord = vertTexCoord;
     fragMatIndex = material_index;
-    fragNormal = (modelMatrix*vec4(normal,1)).xyz;//normalize(normal);
-    
-    mat4 myTrans = partMatricies[objID];
+    int part = int(objID)*4;
+    mat4 myTrans = mat4(texelFetch(partMatricies, part), texelFetch(partMatricies, part+1),
+                        texelFetch(partMatricies, part+2), texelFetch(partMatricies, part+3));
+    fragNormal = (modelMatrix*vec4(mat3(myTrans)*normal,1)).xyz;//normalize(normal);
+
     vec3 ActualVert = (myTrans * vec4(vert,1)).xyz;
 
 
@@ -0,0 +1,578 @@
+package ac
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//The format is described in ac_spec.txt
+
+type ACModel struct {
+	Version   int
+	Materials []ACMat
+	Obj       ACObj
+	Skipped   []ACSkipped //keywords the reader doesn't know, in the order they came
+}
+
+//ACSkipped is an unknown keyword and the line it was passed over on
+type ACSkipped struct {
+	Line  int
+	Token string
+}
+type ACObj struct {
+	Name string
+	Type string //world, poly, group or light
+
+	Data    string //free text attached to the object
+	URL     string
+	Loc     mgl32.Vec3
+	Rot     mgl32.Mat3 //turns the object's vertices and kids, relative to its parent
+	Texture string     //image file, relative to the model's
+	TexRep  mgl32.Vec2 //texture coordinates are scaled by texrep then moved by texoff
+	TexOff  mgl32.Vec2
+	Crease  float32 //degrees, sharper edges aren't smoothed
+	Subdiv  int
+	Hidden  bool
+	Locked  bool
+	Folded  bool
+
+	Mesh    ACMesh
+	NumKids int
+	Kids    []ACObj
+}
+type ACMat struct {
+	Name  string
+	RGB   mgl32.Vec3
+	Amb   mgl32.Vec3
+	Emis  mgl32.Vec3
+	Spec  mgl32.Vec3
+	Shi   int
+	Trans float32
+}
+type ACMesh struct {
+	Verts []mgl32.Vec3
+	Faces []ACFace
+}
+type ACFace struct {
+	Flags       int //surface type in the low 4 bits, shading and two sided above them
+	VertIndices []int
+	UVs         []mgl32.Vec2 //texture coordinate of each vertex
+	MatIndex    int
+	Line        int //where the surface starts in the file
+}
+
+//Surface types, the low 4 bits of a SURF's flags
+const (
+	ACSurfPolygon    = 0
+	ACSurfClosedLine = 1
+	ACSurfLine       = 2
+)
+
+//IsPolygon is true for surfaces that are filled in, lines aren't drawn as triangles
+func (f *ACFace) IsPolygon() bool {
+	return f.Flags&0xf == ACSurfPolygon && len(f.VertIndices) >= 3
+}
+
+//Errors ACError wraps, test for them with errors.Is
+var (
+	ErrACHeader = errors.New("not an AC3D file")
+	ErrACSyntax = errors.New("syntax error")
+	ErrACIndex  = errors.New("index out of range")
+)
+
+//ACError is a problem with an AC3D file and the line it was found on
+type ACError struct {
+	Line int //counted from 1
+	Err  error
+}
+
+func (e *ACError) Error() string {
+	return fmt.Sprintf("ac3d line %d: %v", e.Line, e.Err)
+}
+
+func (e *ACError) Unwrap() error {
+	return e.Err
+}
+
+//newACObj is an object with what the file leaves out at its defaults
+func newACObj() ACObj {
+	return ACObj{Rot: mgl32.Ident3(), TexRep: mgl32.Vec2{1, 1}}
+}
+
+func LoadACFile(fname string) (*ACModel, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := ReadACFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	return m, nil
+}
+
+func ParseACFile(src string) (*ACModel, error) {
+	return ReadACFile(strings.NewReader(src))
+}
+
+//ReadACFile reads an AC3D model a line at a time
+//Every problem with the file comes back as an *ACError
+func ReadACFile(r io.Reader) (*ACModel, error) {
+	ar := acReader{r: bufio.NewReader(r)}
+	m := ACModel{Obj: newACObj()}
+
+	if err := ar.nextLine(); err != nil {
+		return nil, ar.fail(ErrACHeader)
+	}
+	header := ar.tokens[0]
+	if !strings.HasPrefix(header, "AC3D") || len(header) != 5 {
+		return nil, ar.fail(ErrACHeader)
+	}
+	version, err := strconv.ParseInt(header[4:], 16, 32)
+	if err != nil {
+		return nil, ar.fail(fmt.Errorf("%w: version %q", ErrACHeader, header[4:]))
+	}
+	m.Version = int(version)
+
+	objects := []ACObj{}
+	for {
+		err := ar.nextLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch token := ar.word(); token {
+		case "MATERIAL":
+			mat, err := ar.material()
+			if err != nil {
+				return nil, err
+			}
+			m.Materials = append(m.Materials, mat)
+		case "OBJECT":
+			o, err := ar.object()
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, o)
+		default:
+			ar.skip(token)
+		}
+	}
+
+	//Files usually have a single world object holding the rest, others are gathered into one
+	switch len(objects) {
+	case 0:
+	case 1:
+		m.Obj = objects[0]
+	default:
+		m.Obj.Type = "world"
+		m.Obj.Kids = objects
+		m.Obj.NumKids = len(objects)
+	}
+	if err := m.Obj.checkMaterials(len(m.Materials)); err != nil {
+		return nil, err
+	}
+	m.Skipped = ar.skipped
+	return &m, nil
+}
+
+//checkMaterials makes sure every surface's material exists, materials can come after the objects using them
+func (o *ACObj) checkMaterials(materials int) error {
+	for _, f := range o.Mesh.Faces {
+		if f.MatIndex < 0 || f.MatIndex >= materials {
+			return &ACError{f.Line, fmt.Errorf("%w: material %d of %d", ErrACIndex, f.MatIndex, materials)}
+		}
+	}
+	for i := range o.Kids {
+		if err := o.Kids[i].checkMaterials(materials); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//material reads the rest of a MATERIAL line, its properties can come in any order
+func (ar *acReader) material() (ACMat, error) {
+	mat := ACMat{}
+	var err error
+	if mat.Name, err = ar.str("material name"); err != nil {
+		return mat, err
+	}
+	for len(ar.tokens) > 0 {
+		switch key := ar.word(); key {
+		case "rgb":
+			err = ar.floats(key, mat.RGB[:])
+		case "amb":
+			err = ar.floats(key, mat.Amb[:])
+		case "emis":
+			err = ar.floats(key, mat.Emis[:])
+		case "spec":
+			err = ar.floats(key, mat.Spec[:])
+		case "shi":
+			mat.Shi, err = ar.int(key)
+		case "trans":
+			mat.Trans, err = ar.float(key)
+		default:
+			err = ar.fail(fmt.Errorf("%w: unknown material property %q", ErrACSyntax, key))
+		}
+		if err != nil {
+			return mat, err
+		}
+	}
+	return mat, nil
+}
+
+//object reads an object from the rest of its OBJECT line to its kids, and the kids
+func (ar *acReader) object() (ACObj, error) {
+	o := newACObj()
+	start := ar.line
+	var err error
+	if o.Type, err = ar.str("object type"); err != nil {
+		return o, err
+	}
+	for {
+		if err := ar.nextLine(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return o, &ACError{start, fmt.Errorf("%w: object ends without kids", io.ErrUnexpectedEOF)}
+			}
+			return o, err
+		}
+		switch key := ar.word(); key {
+		case "name":
+			o.Name, err = ar.str(key)
+		case "data":
+			var n int
+			if n, err = ar.count(key); err == nil {
+				o.Data, err = ar.raw(n)
+			}
+		case "texture":
+			o.Texture, err = ar.str(key)
+		case "texrep":
+			err = ar.floats(key, o.TexRep[:])
+		case "texoff":
+			err = ar.floats(key, o.TexOff[:])
+		case "rot":
+			err = ar.floats(key, o.Rot[:])
+		case "loc":
+			err = ar.floats(key, o.Loc[:])
+		case "url":
+			o.URL, err = ar.str(key)
+		case "crease":
+			o.Crease, err = ar.float(key)
+		case "subdiv":
+			o.Subdiv, err = ar.int(key)
+		case "hidden":
+			o.Hidden = true
+		case "locked":
+			o.Locked = true
+		case "folded":
+			o.Folded = true
+		case "numvert":
+			err = ar.verts(&o.Mesh)
+		case "numsurf":
+			err = ar.surfaces(&o.Mesh)
+		case "kids":
+			if o.NumKids, err = ar.count(key); err != nil {
+				return o, err
+			}
+			if err := o.Mesh.checkVerts(); err != nil {
+				return o, err
+			}
+			for len(o.Kids) < o.NumKids {
+				if err := ar.nextLine(); err != nil {
+					return o, ar.unexpectedEOF(err, "kid objects")
+				}
+				if token := ar.word(); token != "OBJECT" {
+					return o, ar.fail(fmt.Errorf("%w: %q where a kid OBJECT should be", ErrACSyntax, token))
+				}
+				kid, err := ar.object()
+				if err != nil {
+					return o, err
+				}
+				o.Kids = append(o.Kids, kid)
+			}
+			return o, nil
+		default:
+			ar.skip(key)
+		}
+		if err != nil {
+			return o, err
+		}
+	}
+}
+
+//verts reads the rest of a numvert line and the vertices after it
+//Anything after the coordinates, like the normals some exporters add, is ignored
+func (ar *acReader) verts(mesh *ACMesh) error {
+	n, err := ar.count("numvert")
+	if err != nil {
+		return err
+	}
+	mesh.Verts = mesh.Verts[:0]
+	for i := 0; i < n; i++ {
+		if err := ar.nextLine(); err != nil {
+			return ar.unexpectedEOF(err, "vertices")
+		}
+		v := mgl32.Vec3{}
+		if err := ar.floats("vertex", v[:]); err != nil {
+			return err
+		}
+		mesh.Verts = append(mesh.Verts, v)
+	}
+	return nil
+}
+
+//surfaces reads the rest of a numsurf line and the surfaces after it
+func (ar *acReader) surfaces(mesh *ACMesh) error {
+	n, err := ar.count("numsurf")
+	if err != nil {
+		return err
+	}
+	mesh.Faces = mesh.Faces[:0]
+	for i := 0; i < n; i++ {
+		if err := ar.nextLine(); err != nil {
+			return ar.unexpectedEOF(err, "surfaces")
+		}
+		if token := ar.word(); token != "SURF" {
+			return ar.fail(fmt.Errorf("%w: %q where a SURF should be", ErrACSyntax, token))
+		}
+		f := ACFace{Line: ar.line}
+		if f.Flags, err = ar.int("SURF"); err != nil {
+			return err
+		}
+		//mat is optional, refs ends the surface
+		for refs := false; !refs; {
+			if err := ar.nextLine(); err != nil {
+				return ar.unexpectedEOF(err, "surface refs")
+			}
+			switch key := ar.word(); key {
+			case "mat":
+				f.MatIndex, err = ar.int(key)
+			case "refs":
+				err = ar.refs(&f)
+				refs = true
+			default:
+				err = ar.fail(fmt.Errorf("%w: %q in a surface", ErrACSyntax, key))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		mesh.Faces = append(mesh.Faces, f)
+	}
+	return nil
+}
+
+//refs reads the rest of a refs line and the vertex references after it
+func (ar *acReader) refs(f *ACFace) error {
+	n, err := ar.count("refs")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := ar.nextLine(); err != nil {
+			return ar.unexpectedEOF(err, "refs")
+		}
+		index, err := ar.int("vertex index")
+		if err != nil {
+			return err
+		}
+		uv := mgl32.Vec2{}
+		if err := ar.floats("texture coordinate", uv[:]); err != nil {
+			return err
+		}
+		f.VertIndices = append(f.VertIndices, index)
+		f.UVs = append(f.UVs, uv)
+	}
+	return nil
+}
+
+//checkVerts makes sure every surface only uses vertices the object has, the vertices can come after the surfaces
+func (mesh *ACMesh) checkVerts() error {
+	for _, f := range mesh.Faces {
+		for _, v := range f.VertIndices {
+			if v < 0 || v >= len(mesh.Verts) {
+				return &ACError{f.Line, fmt.Errorf("%w: vertex %d of %d", ErrACIndex, v, len(mesh.Verts))}
+			}
+		}
+	}
+	return nil
+}
+
+//acReader splits an AC3D file into lines of tokens
+type acReader struct {
+	r      *bufio.Reader
+	line   int      //number of the line tokens came from
+	tokens []string //what's left of the line
+
+	skipped []ACSkipped
+}
+
+//nextLine moves on to the next line with anything on it, io.EOF once there are none
+func (ar *acReader) nextLine() error {
+	for {
+		text, err := ar.r.ReadString('\n')
+		if text == "" && err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.EOF
+			}
+			return &ACError{ar.line + 1, err}
+		}
+		ar.line++
+		ar.tokens, err = splitACLine(text)
+		if err != nil {
+			return ar.fail(err)
+		}
+		if len(ar.tokens) > 0 {
+			return nil
+		}
+	}
+}
+
+//splitACLine breaks a line at spaces, except inside quotes which are taken off
+func splitACLine(text string) ([]string, error) {
+	tokens := []string{}
+	text = strings.TrimRight(text, "\r\n")
+	for {
+		text = strings.TrimLeft(text, " \t\r")
+		if text == "" {
+			return tokens, nil
+		}
+		if text[0] == '"' {
+			end := strings.IndexByte(text[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unclosed quote", ErrACSyntax)
+			}
+			tokens = append(tokens, text[1:end+1])
+			text = text[end+2:]
+			continue
+		}
+		end := strings.IndexAny(text, " \t\r")
+		if end < 0 {
+			end = len(text)
+		}
+		tokens = append(tokens, text[:end])
+		text = text[end:]
+	}
+}
+
+//skip notes an unknown keyword, the rest of its line is ignored
+func (ar *acReader) skip(token string) {
+	ar.skipped = append(ar.skipped, ACSkipped{ar.line, token})
+}
+
+func (ar *acReader) fail(err error) error {
+	return &ACError{ar.line, err}
+}
+
+//unexpectedEOF reports the file ending while what was still expected
+func (ar *acReader) unexpectedEOF(err error, what string) error {
+	if errors.Is(err, io.EOF) {
+		return ar.fail(fmt.Errorf("%w: file ends in the middle of the %s", io.ErrUnexpectedEOF, what))
+	}
+	return err
+}
+
+//word takes the next token of the line, empty when there are none
+func (ar *acReader) word() string {
+	if len(ar.tokens) == 0 {
+		return ""
+	}
+	t := ar.tokens[0]
+	ar.tokens = ar.tokens[1:]
+	return t
+}
+
+//str takes the next token of the line, which has to be there
+func (ar *acReader) str(what string) (string, error) {
+	if len(ar.tokens) == 0 {
+		return "", ar.fail(fmt.Errorf("%w: missing %s", ErrACSyntax, what))
+	}
+	return ar.word(), nil
+}
+
+//int takes a decimal, or 0x prefixed hex, integer
+func (ar *acReader) int(what string) (int, error) {
+	s, err := ar.str(what)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return 0, ar.fail(fmt.Errorf("%w: %s %q is not an integer", ErrACSyntax, what, s))
+	}
+	return int(v), nil
+}
+
+//count takes an integer that can't be negative
+func (ar *acReader) count(what string) (int, error) {
+	n, err := ar.int(what)
+	if err == nil && n < 0 {
+		err = ar.fail(fmt.Errorf("%w: %s %d", ErrACSyntax, what, n))
+	}
+	return n, err
+}
+
+func (ar *acReader) float(what string) (float32, error) {
+	s, err := ar.str(what)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return 0, ar.fail(fmt.Errorf("%w: %s %q is not a number", ErrACSyntax, what, s))
+	}
+	return float32(v), nil
+}
+
+//floats fills dst from the next tokens
+func (ar *acReader) floats(what string, dst []float32) error {
+	for i := range dst {
+		v, err := ar.float(what)
+		if err != nil {
+			return err
+		}
+		dst[i] = v
+	}
+	return nil
+}
+
+//raw reads n characters starting on the next line, then skips the rest of the line they finish on
+func (ar *acReader) raw(n int) (string, error) {
+	b := make([]byte, 0, min(n, 4096))
+	for len(b) < n {
+		c, err := ar.r.ReadByte()
+		if err != nil {
+			return "", ar.unexpectedEOF(err, "data")
+		}
+		if c == '\n' {
+			ar.line++
+		}
+		b = append(b, c)
+	}
+	if n == 0 || b[n-1] != '\n' {
+		if _, err := ar.r.ReadString('\n'); err != nil && !errors.Is(err, io.EOF) {
+			return "", ar.fail(err)
+		}
+		ar.line++
+	}
+	ar.tokens = nil
+	return string(b), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
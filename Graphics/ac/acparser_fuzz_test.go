@@ -0,0 +1,43 @@
+//go:build go1.18
+// +build go1.18
+
+package ac
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//FuzzReadACFile starts from every model in Assets, any input must either parse into a model that can be triangulated or give an *ACError
+func FuzzReadACFile(f *testing.F) {
+	f.Add([]byte(testAC))
+	err := filepath.WalkDir("../../Assets", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".ac" {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err == nil {
+			f.Add(src)
+		}
+		return err
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		m, err := ReadACFile(bytes.NewReader(src))
+		if err != nil {
+			var acErr *ACError
+			if !errors.As(err, &acErr) {
+				t.Fatalf("%v is not an *ACError", err)
+			}
+			return
+		}
+		m.Triangles()
+	})
+}
@@ -0,0 +1,92 @@
+package ac
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//A world holding one textured triangle, the line numbers below count from its first line
+const testAC = `AC3Db
+MATERIAL "red" rgb 1 0 0 amb 0.2 0.2 0.2 emis 0 0 0 spec 0.5 0.5 0.5 shi 10 trans 0
+OBJECT world
+kids 1
+OBJECT poly
+name "tri"
+numvert 3
+0 0 0
+1 0 0
+0 1 0
+numsurf 1
+SURF 0x10
+mat 0
+refs 3
+0 0 0
+1 1 0
+2 0 1
+kids 0
+`
+
+func TestParseACFile(t *testing.T) {
+	//Unknown keywords at the top, in the world and in its kid
+	src := strings.Replace(testAC, "AC3Db\n", "AC3Db\nLIGHTS 2\n", 1)
+	src = strings.Replace(src, "OBJECT world\n", "OBJECT world\nshading smooth\n", 1)
+	src = strings.Replace(src, `name "tri"`+"\n", `name "tri"`+"\nnormals 3\n", 1)
+	m, err := ParseACFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != 0xb || len(m.Materials) != 1 || m.Obj.NumKids != 1 || m.Obj.Kids[0].Name != "tri" {
+		t.Errorf("parsed %+v", m)
+	}
+	want := []ACSkipped{{2, "LIGHTS"}, {5, "shading"}, {9, "normals"}}
+	if !reflect.DeepEqual(m.Skipped, want) {
+		t.Errorf("skipped %v, want %v", m.Skipped, want)
+	}
+	if tris := m.Triangles(); len(tris) != 1 {
+		t.Errorf("%d triangles, want 1", len(tris))
+	}
+}
+
+func TestReadACFileErrors(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		old, new string //replaced in testAC, an empty new cuts the file short
+		want     error
+		line     int
+	}{
+		{"not AC3D", "AC3Db", "AC3Xb", ErrACHeader, 1},
+		{"bad version", "AC3Db", "AC3Dz", ErrACHeader, 1},
+		{"unknown material property", "shi 10", "shine 10", ErrACSyntax, 2},
+		{"unclosed quote", `name "tri"`, `name "tri`, ErrACSyntax, 6},
+		{"vertex not a number", "1 0 0\n0 1 0", "1 x 0\n0 1 0", ErrACSyntax, 9},
+		{"negative count", "numvert 3", "numvert -3", ErrACSyntax, 7},
+		{"not a SURF", "SURF 0x10", "SURFACE 0x10", ErrACSyntax, 12},
+		{"unknown surface key", "mat 0", "material 0", ErrACSyntax, 13},
+		{"kid not an OBJECT", "OBJECT poly", "OBJECTS poly", ErrACSyntax, 5},
+		{"vertex out of range", "2 0 1", "3 0 1", ErrACIndex, 12},
+		{"negative vertex", "1 1 0\n2", "-1 1 0\n2", ErrACIndex, 12},
+		{"material out of range", "mat 0", "mat 1", ErrACIndex, 12},
+		{"cut in the vertices", testAC[strings.Index(testAC, "0 1 0"):], "", io.ErrUnexpectedEOF, 9},
+		{"cut in the refs", "2 0 1\nkids 0\n", "", io.ErrUnexpectedEOF, 16},
+		{"no kids line", "kids 0\n", "", io.ErrUnexpectedEOF, 5},
+		{"too few kids", "kids 1\n", "kids 2\n", io.ErrUnexpectedEOF, 18},
+	} {
+		if !strings.Contains(testAC, c.old) {
+			t.Fatalf("%s: %q isn't in the test file", c.name, c.old)
+		}
+		_, err := ParseACFile(strings.Replace(testAC, c.old, c.new, 1))
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+			continue
+		}
+		var acErr *ACError
+		if !errors.As(err, &acErr) {
+			t.Errorf("%s: %v is not an *ACError", c.name, err)
+		} else if acErr.Line != c.line {
+			t.Errorf("%s: %v is on line %d, want %d", c.name, err, acErr.Line, c.line)
+		}
+	}
+}
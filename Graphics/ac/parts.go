@@ -0,0 +1,56 @@
+package ac
+
+import "github.com/go-gl/mathgl/mgl32"
+
+//Part is an object of the file's tree with where it sits
+type Part struct {
+	Obj    *ACObj
+	Parent int        //-1 for the root
+	Local  mgl32.Mat4 //loc and rot, relative to the parent
+	Base   mgl32.Mat4 //Local combined with every parent's, where the file puts the object in model space
+}
+
+//Parts lists every object of the tree, parents before their kids
+func (m *ACModel) Parts() []Part {
+	parts := []Part{}
+	var walk func(o *ACObj, parent int)
+	walk = func(o *ACObj, parent int) {
+		local := mgl32.Translate3D(o.Loc[0], o.Loc[1], o.Loc[2]).Mul4(o.Rot.Mat4())
+		base := local
+		if parent >= 0 {
+			base = parts[parent].Base.Mul4(local)
+		}
+		parts = append(parts, Part{o, parent, local, base})
+		index := len(parts) - 1
+		for i := range o.Kids {
+			walk(&o.Kids[i], index)
+		}
+	}
+	walk(&m.Obj, -1)
+	return parts
+}
+
+//Triangles splits every face of the model into triangles, each part placed where the tree puts it
+func (m *ACModel) Triangles() [][3]mgl32.Vec3 {
+	tris := [][3]mgl32.Vec3{}
+	for _, p := range m.Parts() {
+		mesh := p.Obj.Mesh
+		place := func(v mgl32.Vec3) mgl32.Vec3 {
+			return p.Base.Mul4x1(v.Vec4(1)).Vec3()
+		}
+		for _, f := range mesh.Faces {
+			if !f.IsPolygon() {
+				continue
+			}
+			root := f.VertIndices[0]
+			for i := 1; i < len(f.VertIndices)-1; i++ {
+				tris = append(tris, [3]mgl32.Vec3{
+					place(mesh.Verts[root]),
+					place(mesh.Verts[f.VertIndices[i]]),
+					place(mesh.Verts[f.VertIndices[i+1]]),
+				})
+			}
+		}
+	}
+	return tris
+}
@@ -33,6 +33,7 @@ var points []float32 = []float32{
 type Camera struct {
 	Position mgl32.Vec3
 	Lookat   mgl32.Vec3
+	Up       mgl32.Vec3 //top of the view, world up when zero
 	FOV      float32
 }
 
@@ -55,10 +56,11 @@ type GraphicsContext struct {
 	ShadowTexture     uint32
 	ShadowProgram     uint32
 
-	Cam   Camera
-	Env   *Environment
-	Mod   *Model
-	Scene *Model
+	Cam    Camera
+	Env    *Environment
+	Mod    *Model
+	Scene  *Model
+	Bodies []*Model //everything else the physics moves
 
 	Projection, View mgl32.Mat4
 }
@@ -197,7 +199,11 @@ func (gfx *GraphicsContext) BeginDraw(objPos mgl32.Vec3) {
 
 	//Calculate perspective and view matrices
 	gfx.Projection = mgl32.Perspective(mgl32.DegToRad(gfx.Cam.FOV), float32(gfx.RenderWidth)/float32(gfx.RenderHeight), 0.01, 10000.0)
-	gfx.View = mgl32.LookAtV(gfx.Cam.Position, gfx.Cam.Lookat, [3]float32{0, 1, 0})
+	up := gfx.Cam.Up
+	if up.Len() == 0 {
+		up = mgl32.Vec3{0, 1, 0}
+	}
+	gfx.View = mgl32.LookAtV(gfx.Cam.Position, gfx.Cam.Lookat, up)
 
 	//Bind Framebuffer to render
 	gl.BindFramebuffer(gl.FRAMEBUFFER, gfx.Framebuffer)
@@ -215,6 +221,9 @@ func (gfx *GraphicsContext) BeginDraw(objPos mgl32.Vec3) {
 func (gfx *GraphicsContext) DrawModels() {
 	gfx.Scene.DrawModel(gfx.Projection, gfx.View, gfx.lightSpaceMatrix, gfx.ShadowTexture)
 	gfx.Mod.DrawModel(gfx.Projection, gfx.View, gfx.lightSpaceMatrix, gfx.ShadowTexture)
+	for _, b := range gfx.Bodies {
+		b.DrawModel(gfx.Projection, gfx.View, gfx.lightSpaceMatrix, gfx.ShadowTexture)
+	}
 }
 
 func (gfx *GraphicsContext) RenderScene(IsShadowPass bool) {
@@ -231,6 +240,9 @@ func (gfx *GraphicsContext) RenderScene(IsShadowPass bool) {
 	gfx.Scene.DrawModel(gfx.lightSpaceMatrix, mgl32.Ident4(), gfx.lightSpaceMatrix, 0)
 
 	gfx.Mod.DrawModel(gfx.lightSpaceMatrix, mgl32.Ident4(), gfx.lightSpaceMatrix, 0)
+	for _, b := range gfx.Bodies {
+		b.DrawModel(gfx.lightSpaceMatrix, mgl32.Ident4(), gfx.lightSpaceMatrix, 0)
+	}
 }
 
 func (gfx *GraphicsContext) EndDraw() {
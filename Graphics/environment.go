@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"image"
 	"image/draw"
-	"image/png"
+	_ "image/png"
 	"os"
 
 	"github.com/go-gl/gl/v3.2-core/gl"
@@ -108,7 +108,7 @@ func loadCubemaps(fpath string) uint32 {
 	for i := uint32(0); i < 6; i++ {
 		f, err := os.Open(fpath + sides[i])
 		check(err)
-		img, err := png.Decode(f)
+		img, _, err := image.Decode(f)
 		if err == nil {
 
 			var dataImg *image.RGBA = image.NewRGBA(img.Bounds())
@@ -4,12 +4,17 @@ import (
 	"bufio"
 	_ "embed"
 	"fmt"
-	"io"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/cowsed/GoFly/Graphics/ac"
 	"github.com/go-gl/gl/v3.2-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 )
@@ -27,36 +32,60 @@ type Model struct {
 	ModelMatrix mgl32.Mat4
 
 	shaderMaterials []mgl32.Vec3
-	partMatrices    []mgl32.Mat4
 	numtris         int32
 
-	//To be used when texturing models is supported
-	//texImage        image.RGBA
-	//textureHandle   uint32
+	parts        []modelPart  //every object in the file, parents before their kids
+	partAnim     []mgl32.Mat4 //each part's animation combined with its parents', in model space
+	partMatrices []mgl32.Mat4 //where each part is drawn, worked out from the tree every draw
+	partBuffer   uint32       //holds partMatrices for the vertex shader
+	partTexture  uint32       //buffer texture the shader reads partBuffer through
 
-	mod *ACModel
+	mod *ac.ACModel
+}
+
+//modelPart is an object of the model's tree
+type modelPart struct {
+	name      string
+	parent    int        //index of the parent part, -1 for the root
+	base      mgl32.Mat4 //where the file puts the object in model space, every parent's loc and rot included
+	transform mgl32.Mat4 //animation in model space, applied on top of the parent's
+	points    partRange  //where the part's points are in the vertex buffer
+	texture   uint32     //0 for untextured
 }
 type modelPointInfo struct {
 	vert     mgl32.Vec3
 	normal   mgl32.Vec3
+	uv       mgl32.Vec2
 	matIndex uint32
 	objID    uint32
 }
 
-const modelPointInfoSize = 8
-const maxObjParts = 20 //length of array in shader
+type partRange struct {
+	first, count int32
+}
+
+const modelPointInfoSize = 10
 
 func MakeModel(fname string) *Model {
 	m := Model{}
 	var err error
-	f, err := os.Open(fname)
-	check(err)
-	bytes, err := io.ReadAll(f)
-	check(err)
-	m.mod, err = ParseACFile(string(bytes))
+	m.mod, err = ac.LoadACFile(fname)
 	check(err)
+	if skipped := m.mod.Skipped; len(skipped) > 0 {
+		log.Printf("%s: skipped %d unknown keywords, the first %q on line %d", fname, len(skipped), skipped[0].Token, skipped[0].Line)
+	}
 
-	m.partMatrices, m.shaderMaterials, m.vao, m.vbo, m.numtris = m.mod.ACModelToBuffers()
+	m.shaderMaterials, m.vao, m.vbo, m.numtris, m.parts = ACModelToBuffers(m.mod)
+	m.partAnim = make([]mgl32.Mat4, len(m.parts))
+	m.partMatrices = make([]mgl32.Mat4, len(m.parts))
+	loadTextures(m.mod, filepath.Dir(fname), m.parts)
+
+	//Part matrices are read from a buffer texture, there can be far more of them than fit in uniforms
+	gl.GenBuffers(1, &m.partBuffer)
+	gl.GenTextures(1, &m.partTexture)
+	gl.BindTexture(gl.TEXTURE_BUFFER, m.partTexture)
+	gl.BindBuffer(gl.TEXTURE_BUFFER, m.partBuffer)
+	gl.TexBuffer(gl.TEXTURE_BUFFER, gl.RGBA32F, m.partBuffer)
 	log.Println("Making Model")
 
 	m.program, err = BuildProgram(ModelFragmentSource, ModelVertexSource)
@@ -71,43 +100,57 @@ func MakeModel(fname string) *Model {
 	gl.EnableVertexAttribArray(normAttrib)
 	gl.VertexAttribPointerWithOffset(normAttrib, 3, gl.FLOAT, false, modelPointInfoSize*4, 3*4)
 
+	uvAttrib := uint32(gl.GetAttribLocation(m.program, gl.Str("vertTexCoord\x00")))
+	gl.EnableVertexAttribArray(uvAttrib)
+	gl.VertexAttribPointerWithOffset(uvAttrib, 2, gl.FLOAT, false, modelPointInfoSize*4, 6*4)
+
 	matAttrib := uint32(gl.GetAttribLocation(m.program, gl.Str("material_index\x00")))
 	gl.EnableVertexAttribArray(matAttrib)
-	gl.VertexAttribIPointerWithOffset(matAttrib, 1, gl.UNSIGNED_INT, modelPointInfoSize*4, 6*4)
+	gl.VertexAttribIPointerWithOffset(matAttrib, 1, gl.UNSIGNED_INT, modelPointInfoSize*4, 8*4)
 
 	idAttrib := uint32(gl.GetAttribLocation(m.program, gl.Str("objID\x00")))
 	gl.EnableVertexAttribArray(idAttrib)
-	gl.VertexAttribIPointerWithOffset(idAttrib, 1, gl.UNSIGNED_INT, modelPointInfoSize*4, 7*4)
+	gl.VertexAttribIPointerWithOffset(idAttrib, 1, gl.UNSIGNED_INT, modelPointInfoSize*4, 9*4)
 
 	log.Printf("pasAttrib %v, norm Attrib %v\n", posAttrib, normAttrib)
 
 	return &m
 }
 
-func (m *ACModel) ACModelToBuffers() ([]mgl32.Mat4, []mgl32.Vec3, uint32, uint32, int32) {
+func ACModelToBuffers(m *ac.ACModel) ([]mgl32.Vec3, uint32, uint32, int32, []modelPart) {
 	points := []modelPointInfo{}
-	partMatrices := make([]mgl32.Mat4, m.obj.numkids)
-	for j := range m.obj.kids {
-		if j > maxObjParts {
-			panic("too Many parts of a plane, this should only ever happen as a developer")
+	acParts := m.Parts()
+	parts := make([]modelPart, len(acParts))
+	for j, p := range acParts {
+		obj := p.Obj
+		mesh := obj.Mesh
+		parts[j] = modelPart{
+			name:      obj.Name,
+			parent:    p.Parent,
+			base:      p.Base,
+			transform: mgl32.Ident4(),
+			points:    partRange{first: int32(len(points))},
+		}
+		uv := func(face ac.ACFace, i int) mgl32.Vec2 {
+			t := face.UVs[i]
+			return mgl32.Vec2{t[0]*obj.TexRep[0] + obj.TexOff[0], t[1]*obj.TexRep[1] + obj.TexOff[1]}
 		}
-		mesh := m.obj.kids[j].mesh
-		loc := m.obj.kids[j].loc
-		partMatrices[j] = mgl32.Translate3D(loc[0], loc[1], loc[2])
-		fmt.Println("Making mesh name", m.obj.kids[j].name)
 
-		for _, f := range mesh.faces {
+		for _, f := range mesh.Faces {
+			if !f.IsPolygon() {
+				continue
+			}
 			//Split face into triangles
-			root := f.vertIndices[0]
-			for i := 1; i < len(f.vertIndices)-1; i++ {
-				tri := [3]int{root, f.vertIndices[i], f.vertIndices[i+1]}
-				a := mesh.verts[tri[0]]
-				b := mesh.verts[tri[1]]
-				c := mesh.verts[tri[2]]
+			root := f.VertIndices[0]
+			for i := 1; i < len(f.VertIndices)-1; i++ {
+				tri := [3]int{root, f.VertIndices[i], f.VertIndices[i+1]}
+				a := mesh.Verts[tri[0]]
+				b := mesh.Verts[tri[1]]
+				c := mesh.Verts[tri[2]]
 				norm := CalculateSurfaceNormal(a, b, c)
-				p1 := modelPointInfo{a, norm, uint32(f.matIndex), uint32(j)}
-				p2 := modelPointInfo{b, norm, uint32(f.matIndex), uint32(j)}
-				p3 := modelPointInfo{c, norm, uint32(f.matIndex), uint32(j)}
+				p1 := modelPointInfo{a, norm, uv(f, 0), uint32(f.MatIndex), uint32(j)}
+				p2 := modelPointInfo{b, norm, uv(f, i), uint32(f.MatIndex), uint32(j)}
+				p3 := modelPointInfo{c, norm, uv(f, i+1), uint32(f.MatIndex), uint32(j)}
 
 				points = append(points, p1)
 				points = append(points, p2)
@@ -115,8 +158,8 @@ func (m *ACModel) ACModelToBuffers() ([]mgl32.Mat4, []mgl32.Vec3, uint32, uint32
 			}
 
 		}
+		parts[j].points.count = int32(len(points)) - parts[j].points.first
 	}
-	log.Println(points)
 
 	var vao, vbo uint32
 	gl.GenBuffers(1, &vbo)
@@ -127,12 +170,138 @@ func (m *ACModel) ACModelToBuffers() ([]mgl32.Mat4, []mgl32.Vec3, uint32, uint32
 	gl.BindVertexArray(vao)
 	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
 
-	colors := make([]mgl32.Vec3, len(m.materials))
-	for i := range m.materials {
-		colors[i] = m.materials[i].rgb
+	colors := make([]mgl32.Vec3, len(m.Materials))
+	for i := range m.Materials {
+		colors[i] = m.Materials[i].RGB
+	}
+
+	return colors, vao, vbo, int32(len(points)), parts
+}
+
+//loadTextures loads the image of every textured part, parts sharing a file share the texture
+//Parts whose image can't be read are drawn untextured
+func loadTextures(m *ac.ACModel, dir string, parts []modelPart) {
+	loaded := map[string]uint32{}
+	for j, p := range m.Parts() {
+		obj := p.Obj
+		if obj.Texture == "" {
+			continue
+		}
+		tex, ok := loaded[obj.Texture]
+		if !ok {
+			var err error
+			tex, err = LoadTexture(filepath.Join(dir, obj.Texture))
+			if err != nil {
+				log.Printf("Drawing %s untextured: %v", obj.Name, err)
+			}
+			loaded[obj.Texture] = tex
+		}
+		parts[j].texture = tex
+	}
+}
+
+//LoadTexture uploads an image as a repeating, mipmapped 2D texture
+func LoadTexture(fname string) (uint32, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", fname, err)
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	//Texture coordinates start at the bottom of the image, GL rows start at the first one uploaded
+	width, height := rgba.Rect.Dx(), rgba.Rect.Dy()
+	flipped := make([]uint8, len(rgba.Pix))
+	for y := 0; y < height; y++ {
+		copy(flipped[y*rgba.Stride:(y+1)*rgba.Stride], rgba.Pix[(height-1-y)*rgba.Stride:(height-y)*rgba.Stride])
+	}
+
+	var tex uint32
+	gl.GenTextures(1, &tex)
+	gl.BindTexture(gl.TEXTURE_2D, tex)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(width), int32(height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(flipped))
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	return tex, nil
+}
+
+//PartsNamed finds the indices of the parts with a name, several parts can share one
+//Parts can be anywhere in the tree, moving one moves its kids with it
+func (m *Model) PartsNamed(name string) []int {
+	parts := []int{}
+	for i, p := range m.parts {
+		if p.name == name {
+			parts = append(parts, i)
+		}
+	}
+	return parts
+}
+
+//PartNames lists the name of every part, indexed like PartsNamed
+func (m *Model) PartNames() []string {
+	names := make([]string, len(m.parts))
+	for i, p := range m.parts {
+		names[i] = p.name
 	}
+	return names
+}
 
-	return partMatrices, colors, vao, vbo, int32(len(points))
+//SetPartTransform moves a part by transform in model space, on top of where the file and its parents place it
+func (m *Model) SetPartTransform(part int, transform mgl32.Mat4) {
+	m.parts[part].transform = transform
+}
+
+//updatePartMatrices works out where every part is drawn, parents come first so theirs are ready for their kids
+//The animations chain down the tree, then the part is put where the file places it
+func (m *Model) updatePartMatrices() {
+	for i, p := range m.parts {
+		m.partAnim[i] = p.transform
+		if p.parent >= 0 {
+			m.partAnim[i] = m.partAnim[p.parent].Mul4(p.transform)
+		}
+		m.partMatrices[i] = m.partAnim[i].Mul4(p.base)
+	}
+}
+
+//Bounds are the corners of the box around the model in model space
+func (m *Model) Bounds() (mgl32.Vec3, mgl32.Vec3) {
+	tris := m.mod.Triangles()
+	if len(tris) == 0 {
+		return mgl32.Vec3{}, mgl32.Vec3{}
+	}
+	min, max := tris[0][0], tris[0][0]
+	for _, tri := range tris {
+		for _, v := range tri {
+			for i := range v {
+				if v[i] < min[i] {
+					min[i] = v[i]
+				}
+				if v[i] > max[i] {
+					max[i] = v[i]
+				}
+			}
+		}
+	}
+	return min, max
+}
+
+//Triangles are the faces of the model placed in the world by ModelMatrix
+func (m *Model) Triangles() [][3]mgl32.Vec3 {
+	tris := m.mod.Triangles()
+	for i := range tris {
+		for j := range tris[i] {
+			tris[i][j] = m.ModelMatrix.Mul4x1(tris[i][j].Vec4(1)).Vec3()
+		}
+	}
+	return tris
 }
 
 func (m *Model) DrawModel(projection, view mgl32.Mat4, lightSpaceMatrix mgl32.Mat4, shadowMap uint32) {
@@ -168,8 +337,14 @@ func (m *Model) DrawModel(projection, view mgl32.Mat4, lightSpaceMatrix mgl32.Ma
 	matUniform := gl.GetUniformLocation(m.program, gl.Str("MaterialColors\x00"))
 	gl.Uniform3fv(matUniform, int32(len(m.shaderMaterials)), &m.shaderMaterials[0][0])
 
+	//Part matrices go in the third unit, after the shadow map and part textures
+	m.updatePartMatrices()
+	gl.BindBuffer(gl.TEXTURE_BUFFER, m.partBuffer)
+	gl.BufferData(gl.TEXTURE_BUFFER, len(m.partMatrices)*16*4, gl.Ptr(m.partMatrices), gl.DYNAMIC_DRAW)
+	gl.ActiveTexture(gl.TEXTURE2)
+	gl.BindTexture(gl.TEXTURE_BUFFER, m.partTexture)
 	partMsUniform := gl.GetUniformLocation(m.program, gl.Str(partMatricesName+"\x00"))
-	gl.UniformMatrix4fv(partMsUniform, int32(len(m.partMatrices)), false, &m.partMatrices[0][0])
+	gl.Uniform1i(partMsUniform, 2)
 
 	lsUniform := gl.GetUniformLocation(m.program, gl.Str(lightSpaceMatrixName+"\x00"))
 	gl.UniformMatrix4fv(lsUniform, 1, false, &lightSpaceMatrix[0])
@@ -177,9 +352,28 @@ func (m *Model) DrawModel(projection, view mgl32.Mat4, lightSpaceMatrix mgl32.Ma
 	gl.ActiveTexture(gl.TEXTURE0)
 	gl.BindTexture(gl.TEXTURE_2D, shadowMap)
 
+	//Part textures go in the second unit, the shadow map has the first
+	texUniform := gl.GetUniformLocation(m.program, gl.Str("PartTexture\x00"))
+	gl.Uniform1i(texUniform, 1)
+	texturedUniform := gl.GetUniformLocation(m.program, gl.Str("Textured\x00"))
+
 	gl.Disable(gl.CULL_FACE)
 	gl.BindVertexArray(m.vao)
-	gl.DrawArrays(gl.TRIANGLES, 0, m.numtris)
+	for _, part := range m.parts {
+		if part.points.count == 0 {
+			continue
+		}
+		tex := part.texture
+		gl.ActiveTexture(gl.TEXTURE1)
+		gl.BindTexture(gl.TEXTURE_2D, tex)
+		textured := int32(0)
+		if tex != 0 {
+			textured = 1
+		}
+		gl.Uniform1i(texturedUniform, textured)
+		gl.DrawArrays(gl.TRIANGLES, part.points.first, part.points.count)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
 	gl.Enable(gl.CULL_FACE)
 }
 
@@ -0,0 +1,235 @@
+package graphics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+//SGI image files, the .rgb/.rgba/.bw/.sgi textures CRRCSim and FlightGear models use
+//Registered with the image package so image.Decode reads them
+
+const (
+	sgiMagic      = 474
+	sgiHeaderSize = 512
+)
+
+type sgiHeader struct {
+	Magic     uint16
+	Storage   uint8 //0 verbatim, 1 run length encoded
+	BPC       uint8 //bytes per channel value, 1 or 2
+	Dimension uint16
+	XSize     uint16
+	YSize     uint16
+	ZSize     uint16 //channels
+	PixMin    int32
+	PixMax    int32
+	_         [4]byte
+	Name      [80]byte
+	ColorMap  int32 //0 for plain pixel values, the only kind read
+}
+
+func init() {
+	image.RegisterFormat("sgi", "\x01\xda", DecodeSGI, DecodeSGIConfig)
+}
+
+func readSGIHeader(r io.Reader) (sgiHeader, error) {
+	h := sgiHeader{}
+	var raw [sgiHeaderSize]byte
+	if _, err := io.ReadFull(r, raw[:]); err != nil {
+		return h, fmt.Errorf("sgi header: %v", err)
+	}
+	binary.Read(bytes.NewReader(raw[:]), binary.BigEndian, &h)
+	switch {
+	case h.Magic != sgiMagic:
+		return h, fmt.Errorf("sgi: not an SGI image")
+	case h.Storage > 1:
+		return h, fmt.Errorf("sgi: unknown storage %d", h.Storage)
+	case h.BPC != 1 && h.BPC != 2:
+		return h, fmt.Errorf("sgi: %d bytes per channel, only 1 or 2 are read", h.BPC)
+	case h.ColorMap != 0:
+		return h, fmt.Errorf("sgi: color map %d, only plain images are read", h.ColorMap)
+	}
+	//Lower dimensions leave the unused sizes out
+	switch h.Dimension {
+	case 1:
+		h.YSize, h.ZSize = 1, 1
+	case 2:
+		h.ZSize = 1
+	case 3:
+	default:
+		return h, fmt.Errorf("sgi: dimension %d", h.Dimension)
+	}
+	if h.ZSize < 1 || h.ZSize > 4 {
+		return h, fmt.Errorf("sgi: %d channels, only 1 to 4 are read", h.ZSize)
+	}
+	return h, nil
+}
+
+//DecodeSGIConfig reads the size and color model of an SGI image
+func DecodeSGIConfig(r io.Reader) (image.Config, error) {
+	h, err := readSGIHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: sgiColorModel(h), Width: int(h.XSize), Height: int(h.YSize)}, nil
+}
+
+func sgiColorModel(h sgiHeader) color.Model {
+	switch {
+	case h.ZSize == 1 && h.BPC == 1:
+		return color.GrayModel
+	case h.ZSize == 1:
+		return color.Gray16Model
+	case h.BPC == 1:
+		return color.NRGBAModel
+	}
+	return color.NRGBA64Model
+}
+
+//DecodeSGI reads an SGI image stored verbatim or run length encoded
+//One channel gives a gray image, two gray and alpha, three RGB and four RGBA
+func DecodeSGI(r io.Reader) (image.Image, error) {
+	h, err := readSGIHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	width, height, channels, bpc := int(h.XSize), int(h.YSize), int(h.ZSize), int(h.BPC)
+
+	//Every channel of every row, rows from the bottom of the image up
+	rows := make([][]byte, height*channels)
+	if h.Storage == 0 {
+		br := bufio.NewReader(r)
+		for i := range rows {
+			rows[i] = make([]byte, width*bpc)
+			if _, err := io.ReadFull(br, rows[i]); err != nil {
+				return nil, fmt.Errorf("sgi row %d: %v", i, err)
+			}
+		}
+	} else {
+		//The rest of the file is needed, rows are found by offsets from its start
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("sgi: %v", err)
+		}
+		tables := len(rows) * 4
+		if len(data) < 2*tables {
+			return nil, fmt.Errorf("sgi: offset tables cut short")
+		}
+		for i := range rows {
+			start := int(binary.BigEndian.Uint32(data[i*4:])) - sgiHeaderSize
+			length := int(binary.BigEndian.Uint32(data[tables+i*4:]))
+			if start < 0 || length < 0 || start+length > len(data) {
+				return nil, fmt.Errorf("sgi row %d: outside the file", i)
+			}
+			rows[i], err = sgiExpandRow(data[start:start+length], width, bpc)
+			if err != nil {
+				return nil, fmt.Errorf("sgi row %d: %v", i, err)
+			}
+		}
+	}
+
+	//value is channel c of pixel x on row y counted from the top, scaled to 16 bits
+	value := func(x, y, c int) uint16 {
+		row := rows[c*height+height-1-y]
+		if bpc == 1 {
+			return uint16(row[x]) * 0x101
+		}
+		return binary.BigEndian.Uint16(row[2*x:])
+	}
+	rect := image.Rect(0, 0, width, height)
+	switch {
+	case channels == 1 && bpc == 1:
+		img := image.NewGray(rect)
+		for y := 0; y < height; y++ {
+			copy(img.Pix[y*img.Stride:], rows[height-1-y])
+		}
+		return img, nil
+	case channels == 1:
+		img := image.NewGray16(rect)
+		for y := 0; y < height; y++ {
+			copy(img.Pix[y*img.Stride:], rows[height-1-y])
+		}
+		return img, nil
+	}
+
+	img := image.NewNRGBA64(rect)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA64{A: 0xffff}
+			switch channels {
+			case 2:
+				c.R = value(x, y, 0)
+				c.G, c.B, c.A = c.R, c.R, value(x, y, 1)
+			case 3:
+				c.R, c.G, c.B = value(x, y, 0), value(x, y, 1), value(x, y, 2)
+			case 4:
+				c.R, c.G, c.B, c.A = value(x, y, 0), value(x, y, 1), value(x, y, 2), value(x, y, 3)
+			}
+			img.SetNRGBA64(x, y, c)
+		}
+	}
+	if bpc == 2 {
+		return img, nil
+	}
+	//8 bit images don't need the extra precision
+	small := image.NewNRGBA(rect)
+	for i := range small.Pix {
+		small.Pix[i] = img.Pix[2*i]
+	}
+	return small, nil
+}
+
+//sgiExpandRow undoes the run length encoding of one row of one channel
+//A count byte (or 16 bit value) with the top bit set is followed by that many literal values, without it by one value repeated that many times
+func sgiExpandRow(src []byte, width, bpc int) ([]byte, error) {
+	row := make([]byte, 0, width*bpc)
+	next := func() (int, bool) {
+		if len(src) < bpc {
+			return 0, false
+		}
+		v := int(src[0])
+		if bpc == 2 {
+			v = int(binary.BigEndian.Uint16(src))
+		}
+		src = src[bpc:]
+		return v, true
+	}
+	for {
+		count, ok := next()
+		if !ok {
+			return nil, fmt.Errorf("run length data cut short")
+		}
+		n := count & 0x7f
+		if n == 0 {
+			break
+		}
+		if len(row)+n*bpc > width*bpc {
+			return nil, fmt.Errorf("runs longer than the row")
+		}
+		if count&0x80 != 0 {
+			if len(src) < n*bpc {
+				return nil, fmt.Errorf("run length data cut short")
+			}
+			row = append(row, src[:n*bpc]...)
+			src = src[n*bpc:]
+			continue
+		}
+		if len(src) < bpc {
+			return nil, fmt.Errorf("run length data cut short")
+		}
+		v := src[:bpc]
+		src = src[bpc:]
+		for i := 0; i < n; i++ {
+			row = append(row, v...)
+		}
+	}
+	if len(row) != width*bpc {
+		return nil, fmt.Errorf("runs fill %d of %d values", len(row)/bpc, width)
+	}
+	return row, nil
+}
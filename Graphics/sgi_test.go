@@ -0,0 +1,218 @@
+package graphics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"testing"
+)
+
+func TestDecodeSGITexture(t *testing.T) {
+	f, err := os.Open("../Assets/Planes/angel_s30e.rgb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, format, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "sgi" {
+		t.Errorf("format %q, want sgi", format)
+	}
+	if b := img.Bounds(); b != image.Rect(0, 0, 512, 512) {
+		t.Fatalf("bounds %v, want 512x512", b)
+	}
+	//Pixels counted from the top left, the file stores rows from the bottom
+	for _, c := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{0, 0, color.NRGBA{227, 223, 222, 255}},
+		{511, 511, color.NRGBA{255, 255, 255, 255}},
+		{256, 256, color.NRGBA{225, 221, 218, 255}},
+		{100, 400, color.NRGBA{90, 76, 46, 255}},
+		{400, 100, color.NRGBA{222, 194, 46, 255}},
+	} {
+		if got := img.At(c.x, c.y); got != c.want {
+			t.Errorf("pixel (%d, %d) is %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
+
+//sgiTestValue is channel c of pixel x on row y from the top, varied enough to give both kinds of run
+func sgiTestValue(x, y, c, bpc int) uint16 {
+	v := uint16((x/3)*40 + y*17 + c*60)
+	if x%5 == 4 {
+		v += 7
+	}
+	if bpc == 1 {
+		return v & 0xff
+	}
+	return v*251 + uint16(c)
+}
+
+//encodeSGI writes a test image, run length encoded rows use repeats for equal neighbours and literals otherwise
+func encodeSGI(width, height, channels, bpc int, rle bool) []byte {
+	h := sgiHeader{Magic: sgiMagic, BPC: uint8(bpc), Dimension: 3, XSize: uint16(width), YSize: uint16(height), ZSize: uint16(channels), PixMax: 255}
+	if rle {
+		h.Storage = 1
+	}
+	buf := bytes.Buffer{}
+	binary.Write(&buf, binary.BigEndian, h)
+	buf.Write(make([]byte, sgiHeaderSize-buf.Len()))
+
+	put := func(b *bytes.Buffer, v uint16) {
+		if bpc == 1 {
+			b.WriteByte(byte(v))
+			return
+		}
+		binary.Write(b, binary.BigEndian, v)
+	}
+	rows := [][]uint16{}
+	for c := 0; c < channels; c++ {
+		for r := 0; r < height; r++ {
+			row := make([]uint16, width)
+			for x := range row {
+				row[x] = sgiTestValue(x, height-1-r, c, bpc)
+			}
+			rows = append(rows, row)
+		}
+	}
+	if !rle {
+		for _, row := range rows {
+			for _, v := range row {
+				put(&buf, v)
+			}
+		}
+		return buf.Bytes()
+	}
+
+	data := bytes.Buffer{}
+	starts, lengths := []uint32{}, []uint32{}
+	tables := sgiHeaderSize + 8*len(rows)
+	for _, row := range rows {
+		starts = append(starts, uint32(tables+data.Len()))
+		before := data.Len()
+		for x := 0; x < len(row); {
+			n := 1
+			for x+n < len(row) && row[x+n] == row[x] && n < 127 {
+				n++
+			}
+			if n > 1 {
+				put(&data, uint16(n))
+				put(&data, row[x])
+				x += n
+				continue
+			}
+			for x+n < len(row) && row[x+n] != row[x+n-1] && n < 127 {
+				n++
+			}
+			put(&data, uint16(n|0x80))
+			for _, v := range row[x : x+n] {
+				put(&data, v)
+			}
+			x += n
+		}
+		put(&data, 0)
+		lengths = append(lengths, uint32(data.Len()-before))
+	}
+	binary.Write(&buf, binary.BigEndian, starts)
+	binary.Write(&buf, binary.BigEndian, lengths)
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+//nrgba64At reads a pixel without going through premultiplied alpha, which would round 8 bit values
+func nrgba64At(img image.Image, x, y int) color.NRGBA64 {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		c := img.NRGBAAt(x, y)
+		return color.NRGBA64{uint16(c.R) * 0x101, uint16(c.G) * 0x101, uint16(c.B) * 0x101, uint16(c.A) * 0x101}
+	case *image.NRGBA64:
+		return img.NRGBA64At(x, y)
+	}
+	return color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+}
+
+func TestDecodeSGISynthetic(t *testing.T) {
+	const width, height = 23, 5
+	for _, rle := range []bool{false, true} {
+		for _, bpc := range []int{1, 2} {
+			for _, channels := range []int{1, 2, 3, 4} {
+				data := encodeSGI(width, height, channels, bpc, rle)
+				img, err := DecodeSGI(bytes.NewReader(data))
+				if err != nil {
+					t.Errorf("rle %v, %d bytes, %d channels: %v", rle, bpc, channels, err)
+					continue
+				}
+				conf, err := DecodeSGIConfig(bytes.NewReader(data))
+				if err != nil || conf.Width != width || conf.Height != height || conf.ColorModel != img.ColorModel() {
+					t.Errorf("rle %v, %d bytes, %d channels: config %+v, %v", rle, bpc, channels, conf, err)
+				}
+				scale := func(v uint16) uint16 {
+					if bpc == 1 {
+						return v * 0x101
+					}
+					return v
+				}
+			pixels:
+				for y := 0; y < height; y++ {
+					for x := 0; x < width; x++ {
+						v := func(c int) uint16 { return scale(sgiTestValue(x, y, c, bpc)) }
+						want := color.NRGBA64{A: 0xffff}
+						switch channels {
+						case 1:
+							want.R, want.G, want.B = v(0), v(0), v(0)
+						case 2:
+							want.R, want.G, want.B, want.A = v(0), v(0), v(0), v(1)
+						case 3:
+							want.R, want.G, want.B = v(0), v(1), v(2)
+						case 4:
+							want.R, want.G, want.B, want.A = v(0), v(1), v(2), v(3)
+						}
+						if got := nrgba64At(img, x, y); got != want {
+							t.Errorf("rle %v, %d bytes, %d channels: pixel (%d, %d) is %v, want %v", rle, bpc, channels, x, y, got, want)
+							break pixels
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeSGIBadRunLengths(t *testing.T) {
+	const width, height, channels = 8, 2, 3
+	good := encodeSGI(width, height, channels, 1, true)
+	tables := sgiHeaderSize
+	lengths := tables + 4*height*channels
+	setU32 := func(b []byte, at int, v uint32) []byte {
+		b = append([]byte{}, b...)
+		binary.BigEndian.PutUint32(b[at:], v)
+		return b
+	}
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"offset tables cut short", good[:tables+4*height*channels+3]},
+		{"row data cut short", good[:len(good)-2]},
+		{"start past the end", setU32(good, tables, uint32(len(good)+10))},
+		{"start inside the header", setU32(good, tables, 10)},
+		{"length past the end", setU32(good, lengths, uint32(len(good)))},
+		{"huge start", setU32(good, tables, 0xffffffff)},
+		{"huge length", setU32(good, lengths+4, 0xfffffff0)},
+		{"missing end of row", setU32(good, lengths, 1)},
+		{"runs longer than the row", append(setU32(setU32(good, tables, uint32(len(good))), lengths, 3), 0x7f, 1, 0)},
+	} {
+		if _, err := DecodeSGI(bytes.NewReader(c.data)); err == nil {
+			t.Errorf("%s: decoded without an error", c.name)
+		}
+	}
+	if _, err := DecodeSGI(bytes.NewReader(encodeSGI(width, height, channels, 1, false)[:sgiHeaderSize+10])); err == nil {
+		t.Errorf("truncated verbatim image decoded without an error")
+	}
+}
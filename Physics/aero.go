@@ -0,0 +1,182 @@
+package plane_physics
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Below this airspeed (m/s) the coefficients are meaningless so no aerodynamic forces are produced
+const minAeroSpeed = 0.1
+
+//Width (rad) of the transition from attached flow into a full stall
+const stallTransition = 0.15
+
+//AeroState is the output of the aerodynamic model for one step
+//Forces and moments are in CRRCSim body axes (x forward, y right, z down)
+type AeroState struct {
+	Airspeed float64 //m/s
+	Alpha    float64 //angle of attack, rad
+	Beta     float64 //sideslip, rad
+	Dynamic  float64 //dynamic pressure, Pa
+
+	CL, CD, CY    float64
+	Cl, Cm, Cn    float64
+	StallFraction float64 //0 for attached flow, 1 for fully stalled
+
+	Force  mgl64.Vec3 //N
+	Moment mgl64.Vec3 //N*m, roll pitch yaw
+}
+
+//ComputeAero evaluates the stability derivative model
+//vBody is the velocity relative to the air and omegaBody the angular velocity, both in body axes
+//de, da and dr are the elevator, aileron and rudder inputs in CRRCSim units (-0.5 to 0.5)
+func (ad *AeroData) ComputeAero(vBody, omegaBody mgl64.Vec3, rho, de, da, dr float64) AeroState {
+	as := AeroState{}
+	V := vBody.Len()
+	as.Airspeed = V
+	if V < minAeroSpeed {
+		return as
+	}
+	u, v, w := vBody[0], vBody[1], vBody[2]
+	as.Alpha = math.Atan2(w, u)
+	as.Beta = math.Asin(clamp(v/V, -1, 1))
+	as.Dynamic = 0.5 * rho * V * V
+
+	ref := ad.Ref
+	//Non dimensional rates
+	pHat := omegaBody[0] * ref.Span / (2 * V)
+	qHat := omegaBody[1] * ref.Chord / (2 * V)
+	rHat := omegaBody[2] * ref.Span / (2 * V)
+
+	alpha := as.Alpha
+	lift := ad.Lift
+	as.StallFraction = ad.stallFraction(alpha)
+	s := as.StallFraction
+
+	//Attached flow lift, limited to CL_max/CL_min and reduced by CL_drop as the wing stalls
+	CLatt := clamp(lift.CL0+lift.CLa*alpha, lift.CLMin, lift.CLMax) * (1 - lift.CLDrop*s)
+	CLflat := 2 * math.Sin(alpha) * math.Cos(alpha)
+	as.CL = (1-s)*CLatt + s*CLflat + lift.CLq*qHat + lift.CLde*de
+
+	drag := ad.Drag
+	profile := drag.CDProf
+	if ref.Speed > 0 {
+		profile *= math.Pow(V/ref.Speed, drag.UexpCD)
+	}
+	CLoff := as.CL - lift.CLCD0
+	as.CD = profile + drag.CDCLsq*CLoff*CLoff + drag.CDAIsq*da*da + drag.CDELsq*de*de
+	sa := math.Sin(alpha)
+	as.CD += s * (drag.CDStall + 2*sa*sa)
+
+	as.CY = ad.Y.CYb*as.Beta + ad.Y.CYp*pHat + ad.Y.CYr*rHat + ad.Y.CYdr*dr + ad.Y.CYda*da
+	as.Cl = ad.L.Clb*as.Beta + ad.L.Clp*pHat + ad.L.Clr*rHat + ad.L.Cldr*dr + ad.L.Clda*da
+	as.Cm = ad.M.Cm0 + ad.M.Cma*alpha + ad.M.Cmq*qHat + ad.M.Cmde*de
+	as.Cn = ad.N.Cnb*as.Beta + ad.N.Cnp*pHat + ad.N.Cnr*rHat + ad.N.Cndr*dr + ad.N.Cnda*da
+
+	qS := as.Dynamic * ref.Area
+	L := as.CL * qS
+	D := as.CD * qS
+	//Lift and drag are in the stability axes, rotate them by alpha into the body axes
+	ca := math.Cos(alpha)
+	as.Force = mgl64.Vec3{
+		L*sa - D*ca,
+		as.CY * qS,
+		-L*ca - D*sa,
+	}
+	as.Moment = mgl64.Vec3{
+		as.Cl * qS * ref.Span,
+		as.Cm * qS * ref.Chord,
+		as.Cn * qS * ref.Span,
+	}
+	return as
+}
+
+//stallFraction ramps from 0 at the stall angle to 1 stallTransition radians past it
+func (ad *AeroData) stallFraction(alpha float64) float64 {
+	lift := ad.Lift
+	if lift.CLa <= 0 {
+		return 0
+	}
+	alphaMax := (lift.CLMax - lift.CL0) / lift.CLa
+	alphaMin := (lift.CLMin - lift.CL0) / lift.CLa
+	past := 0.0
+	if alpha > alphaMax {
+		past = alpha - alphaMax
+	} else if alpha < alphaMin {
+		past = alphaMin - alpha
+	}
+	return clamp(past/stallTransition, 0, 1)
+}
+
+//AeroForces computes the aerodynamic force and torque in world space on p in its current state
+//Nothing is stored, the integrator calls this for states it may not keep
+func (ps *PhysicsSim) AeroForces(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+	if ps.Airplane == nil {
+		return mgl64.Vec3{}, mgl64.Vec3{}
+	}
+	as, _, _ := ps.aeroAt(p)
+	force := p.Orientation.Rotate(BodyToModel(as.Force))
+	torque := p.Orientation.Rotate(BodyToModel(as.Moment))
+	return force, torque
+}
+
+//aeroAt evaluates the aerodynamic model for p, along with the wind and air it is flying through
+func (ps *PhysicsSim) aeroAt(p *PhysicsObject) (AeroState, mgl64.Vec3, AirProperties) {
+	wind := mgl64.Vec3{}
+	if ps.Wind != nil {
+		wind = ps.Wind.WindAt(p.Position, ps.SumDT)
+	}
+	toModel := p.Orientation.Conjugate()
+	vBody := ModelToBody(toModel.Rotate(p.Velocity().Sub(wind)))
+	omegaBody := ModelToBody(toModel.Rotate(p.AngularVelocity()))
+
+	air := ps.Atmosphere.At(p.Position[1])
+	de, da, dr := ps.Controls.AeroDeflections()
+	return ps.Airplane.Aero.ComputeAero(vBody, omegaBody, air.Density, de, da, dr), wind, air
+}
+
+//updateAero stores the aerodynamic state of the airplane after a step
+func (ps *PhysicsSim) updateAero() {
+	if ps.Airplane == nil {
+		return
+	}
+	ps.Aero, ps.LocalWind, ps.Air = ps.aeroAt(ps.Model)
+}
+
+//LoadFactor is the aerodynamic and propeller force along the airplane's up axis in g, from the last step
+func (ps *PhysicsSim) LoadFactor() float64 {
+	force := ps.Aero.Force
+	if ps.Power != nil {
+		thrust, _ := ps.Power.Forces()
+		force = force.Add(thrust)
+	}
+	//Body z points down
+	return -force[2] / (ps.Model.Mass * -g)
+}
+
+//TrimAlpha is the angle of attack (rad) the airplane settles at with the elevator centered
+func (ad *AeroData) TrimAlpha() float64 {
+	if ad.M.Cma >= 0 {
+		//Not statically stable, there is no trim point
+		return 0
+	}
+	return -ad.M.Cm0 / ad.M.Cma
+}
+
+//TrimSpeed is the speed (m/s) of steady gliding flight with the elevator centered
+func (ad *AeroData) TrimSpeed(mass, rho float64) float64 {
+	lift := ad.Lift
+	CL := clamp(lift.CL0+lift.CLa*ad.TrimAlpha(), 0.1, 0.9*lift.CLMax)
+	return math.Sqrt(2 * mass * -g / (rho * ad.Ref.Area * CL))
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
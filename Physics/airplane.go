@@ -0,0 +1,478 @@
+package plane_physics
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Unit conversions for CRRCSim files written with units="0" (ft, slug, lbf)
+const (
+	ftToM         = 0.3048
+	slugToKg      = 14.5939029
+	slugFt2ToKgM2 = 1.35581795
+	lbfToN        = 4.44822162
+	lbfPerFtToNPM = lbfToN / ftToM
+)
+
+//UnitsImperial and UnitsSI are the values of the units attribute in CRRCSim files
+const (
+	UnitsImperial = 0
+	UnitsSI       = 1
+)
+
+//bodyToModel converts CRRCSim body axes (x forward, y right, z down) into model axes (x left, y up, z forward)
+var bodyToModel = mgl64.Mat3{
+	0, 0, 1,
+	-1, 0, 0,
+	0, -1, 0,
+}
+
+//BodyToModel converts a vector in CRRCSim body axes to the model space used by the PhysicsObject
+func BodyToModel(v mgl64.Vec3) mgl64.Vec3 {
+	return bodyToModel.Mul3x1(v)
+}
+
+//ModelToBody converts a vector in model space to CRRCSim body axes
+func ModelToBody(v mgl64.Vec3) mgl64.Vec3 {
+	return bodyToModel.Transpose().Mul3x1(v)
+}
+
+//Airplane is a CRRCSim airplane description (Assets/Planes/*.xml)
+//All values are converted to SI units when loaded
+//The <CG> element isn't read, wheel and propeller positions are already given relative to the CG
+type Airplane struct {
+	XMLName    xml.Name         `xml:"CRRCSim_airplane"`
+	Version    int              `xml:"version,attr"`
+	Name       string           `xml:"name>en"`
+	Aero       AeroData         `xml:"aero"`
+	Configs    []AirplaneConfig `xml:"config"`
+	Graphics   []GraphicsInfo   `xml:"graphics"`
+	Wheels     WheelSet         `xml:"wheels"`
+	Animations []AnimationSpec  `xml:"animations>animation"`
+	Launch     []LaunchPreset   `xml:"launch>preset"`
+
+	//Directory the file was loaded from, used to find the graphics model
+	dir string
+}
+
+type AeroData struct {
+	Units int      `xml:"units,attr"`
+	Ref   AeroRef  `xml:"ref"`
+	Misc  AeroMisc `xml:"misc"`
+	Lift  AeroLift `xml:"lift"`
+	Drag  AeroDrag `xml:"drag"`
+	Y     AeroY    `xml:"Y"`
+	L     AeroL    `xml:"l"`
+	M     AeroM    `xml:"m"`
+	N     AeroN    `xml:"n"`
+	Prop  AeroProp `xml:"prop"`
+}
+
+type AeroRef struct {
+	Chord float64 `xml:"chord,attr"`
+	Span  float64 `xml:"span,attr"`
+	Area  float64 `xml:"area,attr"`
+	Speed float64 `xml:"speed,attr"`
+}
+type AeroMisc struct {
+	Alpha0  float64 `xml:"Alpha_0,attr"`
+	EtaLoc  float64 `xml:"eta_loc,attr"`
+	CGArm   float64 `xml:"CG_arm,attr"`
+	SpanEff float64 `xml:"span_eff,attr"`
+}
+type AeroLift struct {
+	CL0    float64 `xml:"CL_0,attr"`
+	CLMax  float64 `xml:"CL_max,attr"`
+	CLMin  float64 `xml:"CL_min,attr"`
+	CLa    float64 `xml:"CL_a,attr"`
+	CLq    float64 `xml:"CL_q,attr"`
+	CLde   float64 `xml:"CL_de,attr"`
+	CLDrop float64 `xml:"CL_drop,attr"`
+	CLCD0  float64 `xml:"CL_CD0,attr"`
+}
+type AeroDrag struct {
+	CDProf  float64 `xml:"CD_prof,attr"`
+	UexpCD  float64 `xml:"Uexp_CD,attr"`
+	CDStall float64 `xml:"CD_stall,attr"`
+	CDCLsq  float64 `xml:"CD_CLsq,attr"`
+	CDAIsq  float64 `xml:"CD_AIsq,attr"`
+	CDELsq  float64 `xml:"CD_ELsq,attr"`
+}
+
+//Side force
+type AeroY struct {
+	CYb  float64 `xml:"CY_b,attr"`
+	CYp  float64 `xml:"CY_p,attr"`
+	CYr  float64 `xml:"CY_r,attr"`
+	CYdr float64 `xml:"CY_dr,attr"`
+	CYda float64 `xml:"CY_da,attr"`
+}
+
+//Roll moment
+type AeroL struct {
+	Clb  float64 `xml:"Cl_b,attr"`
+	Clp  float64 `xml:"Cl_p,attr"`
+	Clr  float64 `xml:"Cl_r,attr"`
+	Cldr float64 `xml:"Cl_dr,attr"`
+	Clda float64 `xml:"Cl_da,attr"`
+}
+
+//Pitch moment
+type AeroM struct {
+	Cm0  float64 `xml:"Cm_0,attr"`
+	Cma  float64 `xml:"Cm_a,attr"`
+	Cmq  float64 `xml:"Cm_q,attr"`
+	Cmde float64 `xml:"Cm_de,attr"`
+}
+
+//Yaw moment
+type AeroN struct {
+	Cnb  float64 `xml:"Cn_b,attr"`
+	Cnp  float64 `xml:"Cn_p,attr"`
+	Cnr  float64 `xml:"Cn_r,attr"`
+	Cndr float64 `xml:"Cn_dr,attr"`
+	Cnda float64 `xml:"Cn_da,attr"`
+}
+type AeroProp struct {
+	TorqueFactor float64 `xml:"torquefactor,attr"`
+}
+
+//AirplaneConfig is one of the <config> variants of an airplane (ballast, motor, etc)
+type AirplaneConfig struct {
+	Description string      `xml:"descr_short>en"`
+	LongDescr   string      `xml:"descr_long>en"`
+	MassInertia MassInertia `xml:"mass_inertia"`
+	Power       *PowerSpec  `xml:"power"`
+}
+
+//MassInertia is in kg and kg*m^2 in CRRCSim body axes
+type MassInertia struct {
+	Units int     `xml:"units,attr"`
+	Mass  float64 `xml:"Mass,attr"`
+	Ixx   float64 `xml:"I_xx,attr"`
+	Iyy   float64 `xml:"I_yy,attr"`
+	Izz   float64 `xml:"I_zz,attr"`
+	Ixz   float64 `xml:"I_xz,attr"`
+}
+
+//PowerSpec is the <power> block. CRRCSim always describes the power train in SI units
+type PowerSpec struct {
+	Units     int             `xml:"units,attr"`
+	Automagic *PowerAutomagic `xml:"automagic"`
+	Batteries []BatterySpec   `xml:"battery"`
+}
+type PowerAutomagic struct {
+	F         float64       `xml:"F,attr"`
+	V         float64       `xml:"V,attr"`
+	Batteries []BatterySpec `xml:"battery"`
+}
+type BatterySpec struct {
+	Filename    string            `xml:"filename,attr"`
+	C           float64           `xml:"C,attr"`
+	U0          float64           `xml:"U_0,attr"`
+	UOff        float64           `xml:"U_off,attr"`
+	RI          float64           `xml:"R_I,attr"`
+	ThrottleMin float64           `xml:"throttle_min,attr"`
+	U0RelText   string            `xml:"U_0rel"`
+	Automagic   *BatteryAutomagic `xml:"automagic"`
+	Shafts      []ShaftSpec       `xml:"shaft"`
+
+	//Relative open circuit voltage over discharge, parsed from U0RelText
+	U0Rel []float64 `xml:"-"`
+}
+type BatteryAutomagic struct {
+	T float64 `xml:"T,attr"` //s of flight at full throttle
+}
+type ShaftSpec struct {
+	J          float64         `xml:"J,attr"`
+	Brake      float64         `xml:"brake,attr"`
+	Propellers []PropellerSpec `xml:"propeller"`
+	Engines    []EngineSpec    `xml:"engine"`
+}
+type PropellerSpec struct {
+	D          float64       `xml:"D,attr"`
+	H          float64       `xml:"H,attr"`
+	J          float64       `xml:"J,attr"`
+	NFold      float64       `xml:"n_fold,attr"`
+	DownThrust float64       `xml:"downthrust,attr"`
+	Pos        *PropellerPos `xml:"pos"`
+}
+
+//PropellerPos places the propeller relative to the CG in body axes, the thrust line angles are in degrees
+type PropellerPos struct {
+	XMLPos
+	DownThrust  float64 `xml:"downthrust,attr"`
+	RightThrust float64 `xml:"rightthrust,attr"`
+}
+type EngineSpec struct {
+	Filename  string           `xml:"filename,attr"`
+	Automagic *EngineAutomagic `xml:"automagic"`
+}
+type EngineAutomagic struct {
+	OmegaP float64 `xml:"omega_p,attr"`
+	EtaOpt float64 `xml:"eta_opt,attr"`
+	Eta    float64 `xml:"eta,attr"`
+}
+
+type XMLPos struct {
+	X float64 `xml:"x,attr"`
+	Y float64 `xml:"y,attr"`
+	Z float64 `xml:"z,attr"`
+}
+
+//Vec returns the position as a vector
+func (p XMLPos) Vec() mgl64.Vec3 {
+	return mgl64.Vec3{p.X, p.Y, p.Z}
+}
+
+type GraphicsInfo struct {
+	Model       string `xml:"model,attr"`
+	Description string `xml:"descr_short>en"`
+}
+
+type WheelSet struct {
+	Units  int         `xml:"units,attr"`
+	Wheels []WheelSpec `xml:"wheel"`
+}
+
+//WheelSpec is a landing gear or skid point, position in m in body axes
+type WheelSpec struct {
+	PercentBrake   float64       `xml:"percent_brake,attr"`
+	CasterAngleRad float64       `xml:"caster_angle_rad,attr"`
+	Pos            XMLPos        `xml:"pos"`
+	Spring         SpringSpec    `xml:"spring"`
+	Steering       *SteeringSpec `xml:"steering"`
+}
+
+//SpringSpec has constant in N/m, damping in N*s/m and max_force in N
+type SpringSpec struct {
+	Constant float64 `xml:"constant,attr"`
+	Damping  float64 `xml:"damping,attr"`
+	MaxForce float64 `xml:"max_force,attr"`
+}
+type SteeringSpec struct {
+	Mapping  string  `xml:"mapping,attr"`
+	MaxAngle float64 `xml:"max_angle,attr"`
+}
+
+//AnimationSpec is a <animation> entry, for now only ControlSurface is used
+type AnimationSpec struct {
+	Type   string `xml:"type,attr"`
+	Object struct {
+		Name     string  `xml:"name,attr"`
+		MaxAngle float64 `xml:"max_angle,attr"`
+	} `xml:"object"`
+	Control struct {
+		Mapping string  `xml:"mapping,attr"`
+		Gain    float64 `xml:"gain,attr"`
+	} `xml:"control"`
+	Hinges []XMLPos `xml:"hinge"`
+}
+
+//LaunchPreset is a <launch><preset> entry. Distances are converted to m
+//Altitude is above the ground, Angle is the pitch in rad and the speed is VelocityRel times the trim speed
+type LaunchPreset struct {
+	Name        string  `xml:"name_en,attr"`
+	Altitude    float64 `xml:"altitude,attr"`
+	VelocityRel float64 `xml:"velocity_rel,attr"`
+	Angle       float64 `xml:"angle,attr"`
+	SAL         int     `xml:"sal,attr"`
+	RelToPlayer int     `xml:"rel_to_player,attr"` //RelFront and RelRight are from the pilot, otherwise from the field's origin
+	RelFront    float64 `xml:"rel_front,attr"`
+	RelRight    float64 `xml:"rel_right,attr"`
+}
+
+//LoadAirplane reads a CRRCSim airplane file from disk
+func LoadAirplane(fname string) (*Airplane, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	a, err := ParseAirplane(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fname, err)
+	}
+	a.dir = filepath.Dir(fname)
+	return a, nil
+}
+
+//ParseAirplane decodes a CRRCSim airplane description and converts it to SI units
+func ParseAirplane(r io.Reader) (*Airplane, error) {
+	a := Airplane{}
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+	if err := dec.Decode(&a); err != nil {
+		return nil, fmt.Errorf("error decoding airplane: %v", err)
+	}
+	if len(a.Configs) == 0 {
+		return nil, fmt.Errorf("airplane %q has no config", a.Name)
+	}
+	a.Name = strings.TrimSpace(a.Name)
+	a.Aero.toSI()
+	for i := range a.Configs {
+		if err := a.Configs[i].toSI(); err != nil {
+			return nil, fmt.Errorf("config %d: %v", i, err)
+		}
+	}
+	a.Wheels.toSI()
+	for i := range a.Launch {
+		a.Launch[i].Name = strings.TrimSpace(a.Launch[i].Name)
+		a.Launch[i].Altitude *= ftToM
+		a.Launch[i].RelFront *= ftToM
+		a.Launch[i].RelRight *= ftToM
+	}
+	return &a, nil
+}
+
+//Config finds a config by its short description, an empty name gives the default (first) config
+func (a *Airplane) Config(name string) (*AirplaneConfig, error) {
+	if name == "" {
+		return &a.Configs[0], nil
+	}
+	for i := range a.Configs {
+		if a.Configs[i].Description == name {
+			return &a.Configs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("airplane %q has no config %q, have %v", a.Name, name, a.ConfigNames())
+}
+
+//ConfigNames lists the short descriptions of every config
+func (a *Airplane) ConfigNames() []string {
+	names := make([]string, len(a.Configs))
+	for i := range a.Configs {
+		names[i] = a.Configs[i].Description
+	}
+	return names
+}
+
+//ModelPath is the path of the default graphics model, relative to the working directory
+func (a *Airplane) ModelPath() string {
+	if len(a.Graphics) == 0 {
+		return ""
+	}
+	return filepath.Join(a.dir, a.Graphics[0].Model)
+}
+
+//ApplyTo sets the mass and inertia of a PhysicsObject from this config
+func (c *AirplaneConfig) ApplyTo(p *PhysicsObject) {
+	mi := c.MassInertia
+	p.Mass = mi.Mass
+	p.InertiaTensor = bodyToModel.Mul3(mi.Tensor()).Mul3(bodyToModel.Transpose())
+}
+
+//Tensor is the full inertia tensor in body axes
+//CRRCSim gives the product of inertia I_xz as a positive integral so it enters the tensor negated
+func (mi MassInertia) Tensor() mgl64.Mat3 {
+	return mgl64.Mat3{
+		mi.Ixx, 0, -mi.Ixz,
+		0, mi.Iyy, 0,
+		-mi.Ixz, 0, mi.Izz,
+	}
+}
+
+func (ad *AeroData) toSI() {
+	if ad.Units != UnitsImperial {
+		return
+	}
+	ad.Ref.Chord *= ftToM
+	ad.Ref.Span *= ftToM
+	ad.Ref.Area *= ftToM * ftToM
+	ad.Ref.Speed *= ftToM
+	ad.Units = UnitsSI
+}
+
+func (c *AirplaneConfig) toSI() error {
+	c.Description = strings.TrimSpace(c.Description)
+	c.LongDescr = strings.TrimSpace(c.LongDescr)
+	if c.MassInertia.Units == UnitsImperial {
+		c.MassInertia.Mass *= slugToKg
+		c.MassInertia.Ixx *= slugFt2ToKgM2
+		c.MassInertia.Iyy *= slugFt2ToKgM2
+		c.MassInertia.Izz *= slugFt2ToKgM2
+		c.MassInertia.Ixz *= slugFt2ToKgM2
+		c.MassInertia.Units = UnitsSI
+	}
+	if c.MassInertia.Mass <= 0 {
+		return fmt.Errorf("mass must be positive, got %v", c.MassInertia.Mass)
+	}
+	if c.Power == nil {
+		return nil
+	}
+	for i := range c.Power.Batteries {
+		if err := c.Power.Batteries[i].parseU0Rel(); err != nil {
+			return err
+		}
+	}
+	if c.Power.Automagic != nil {
+		for i := range c.Power.Automagic.Batteries {
+			if err := c.Power.Automagic.Batteries[i].parseU0Rel(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//parseU0Rel reads the ; separated discharge curve
+func (b *BatterySpec) parseU0Rel() error {
+	b.U0Rel = nil
+	for _, s := range strings.Split(b.U0RelText, ";") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("bad U_0rel value %q: %v", s, err)
+		}
+		b.U0Rel = append(b.U0Rel, v)
+	}
+	return nil
+}
+
+func (ws *WheelSet) toSI() {
+	if ws.Units != UnitsImperial {
+		return
+	}
+	for i := range ws.Wheels {
+		w := &ws.Wheels[i]
+		w.Pos = w.Pos.scale(ftToM)
+		w.Spring.Constant *= lbfPerFtToNPM
+		w.Spring.Damping *= lbfPerFtToNPM
+		w.Spring.MaxForce *= lbfToN
+	}
+	ws.Units = UnitsSI
+}
+
+func (p XMLPos) scale(s float64) XMLPos {
+	return XMLPos{p.X * s, p.Y * s, p.Z * s}
+}
+
+//charsetReader handles the iso-8859-1 encoding CRRCSim files are saved with
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		src, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		buf := bytes.Buffer{}
+		for _, b := range src {
+			buf.WriteRune(rune(b))
+		}
+		return &buf, nil
+	case "utf-8":
+		return input, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
@@ -0,0 +1,38 @@
+package plane_physics
+
+import "github.com/go-gl/mathgl/mgl64"
+
+//Animation types of <animation>
+const AnimationControlSurface = "ControlSurface"
+
+//crrcChannel is a channel with CRRCSim's signs, which animations are written for
+//Positive elevator is push and positive rudder is left, like the inputs of AeroDeflections
+func (c ControlState) crrcChannel(mapping string) float64 {
+	switch mapping {
+	case ChannelElevator:
+		return -c.Elevator
+	case ChannelRudder:
+		return -c.Rudder
+	}
+	return c.Channel(mapping)
+}
+
+//Angle is how far (rad) the animated object is turned about its hinge for the controls
+func (a *AnimationSpec) Angle(c ControlState) float64 {
+	return c.crrcChannel(a.Control.Mapping) * a.Control.Gain * a.Object.MaxAngle
+}
+
+//Hinge is a point on the hinge line and the direction of the line in model space
+//Positive angles turn the object right handed about the direction
+func (a *AnimationSpec) Hinge() (mgl64.Vec3, mgl64.Vec3, bool) {
+	if len(a.Hinges) < 2 {
+		return mgl64.Vec3{}, mgl64.Vec3{}, false
+	}
+	p1 := BodyToModel(a.Hinges[0].Vec())
+	p2 := BodyToModel(a.Hinges[1].Vec())
+	axis := p2.Sub(p1)
+	if axis.Len() < 1e-9 {
+		return mgl64.Vec3{}, mgl64.Vec3{}, false
+	}
+	return p1, axis.Normalize(), true
+}
@@ -0,0 +1,56 @@
+package plane_physics
+
+import "math"
+
+//International Standard Atmosphere constants
+const (
+	isaSeaLevelTemperature = 288.15   //K
+	isaSeaLevelPressure    = 101325.0 //Pa
+	isaLapseRate           = 0.0065   //K/m in the troposphere
+	isaTropopause          = 11000.0  //m
+	isaStratosphereTop     = 20000.0  //m, the model is held constant above this
+	airGasConstant         = 287.053  //J/(kg*K)
+	airHeatRatio           = 1.4
+	standardGravity        = 9.80665 //m/s^2
+)
+
+//Atmosphere is the ISA model shifted for the local conditions
+type Atmosphere struct {
+	FieldElevation    float64 //m above sea level of the world's y=0
+	TemperatureOffset float64 //K added to the ISA temperature at every altitude
+	PressureOffset    float64 //Pa added to the ISA sea level pressure
+}
+
+//AirProperties describe the air at one point
+type AirProperties struct {
+	Altitude     float64 //m above sea level
+	Temperature  float64 //K
+	Pressure     float64 //Pa
+	Density      float64 //kg/m^3
+	SpeedOfSound float64 //m/s
+}
+
+//At gives the air properties at a world height y
+func (a *Atmosphere) At(y float64) AirProperties {
+	h := clamp(a.FieldElevation+y, 0, isaStratosphereTop)
+	ap := AirProperties{Altitude: a.FieldElevation + y}
+
+	seaLevelPressure := isaSeaLevelPressure + a.PressureOffset
+	//Exponent of the pressure ratio in the troposphere, g/(R*L)
+	exponent := standardGravity / (airGasConstant * isaLapseRate)
+
+	isaTemperature := isaSeaLevelTemperature - isaLapseRate*math.Min(h, isaTropopause)
+	if h <= isaTropopause {
+		ap.Pressure = seaLevelPressure * math.Pow(isaTemperature/isaSeaLevelTemperature, exponent)
+	} else {
+		//Isothermal layer above the tropopause
+		tropopauseTemperature := isaSeaLevelTemperature - isaLapseRate*isaTropopause
+		tropopausePressure := seaLevelPressure * math.Pow(tropopauseTemperature/isaSeaLevelTemperature, exponent)
+		ap.Pressure = tropopausePressure * math.Exp(-standardGravity*(h-isaTropopause)/(airGasConstant*tropopauseTemperature))
+	}
+
+	ap.Temperature = isaTemperature + a.TemperatureOffset
+	ap.Density = ap.Pressure / (airGasConstant * ap.Temperature)
+	ap.SpeedOfSound = math.Sqrt(airHeatRatio * airGasConstant * ap.Temperature)
+	return ap
+}
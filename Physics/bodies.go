@@ -0,0 +1,542 @@
+package plane_physics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Names of the collision shapes used in the config
+const (
+	ShapeBox    = "box"
+	ShapeSphere = "sphere"
+)
+
+var ShapeNames = []string{ShapeBox, ShapeSphere}
+
+//Contact solver tuning
+const (
+	contactIterations  = 8
+	contactRestitution = 0.5
+	contactFriction    = 0.5
+	restingSpeed       = 0.2   //m/s, contacts closing slower than this don't bounce
+	penetrationSlop    = 0.002 //m of overlap left alone so resting contacts don't jitter
+	positionCorrection = 0.4   //fraction of the overlap pushed apart each step
+)
+
+//Shape is the collision geometry of a body, in model space around its center of mass
+type Shape interface {
+	//Bounds is the world space axis aligned box around the shape on body p
+	Bounds(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3)
+	//GroundPoints are the world space points tested against the ground
+	GroundPoints(p *PhysicsObject) []mgl64.Vec3
+	//Inertia is the inertia tensor of a solid of this shape
+	Inertia(mass float64) mgl64.Mat3
+}
+
+//Sphere is a ball centered on the center of mass
+type Sphere struct {
+	Radius float64
+}
+
+func (s Sphere) Bounds(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+	r := mgl64.Vec3{s.Radius, s.Radius, s.Radius}
+	return p.Position.Sub(r), p.Position.Add(r)
+}
+
+func (s Sphere) GroundPoints(p *PhysicsObject) []mgl64.Vec3 {
+	return []mgl64.Vec3{p.Position.Sub(mgl64.Vec3{0, s.Radius, 0})}
+}
+
+func (s Sphere) Inertia(mass float64) mgl64.Mat3 {
+	return mgl64.Ident3().Mul(0.4 * mass * s.Radius * s.Radius)
+}
+
+//Box is an oriented box, Offset moves its center away from the center of mass
+type Box struct {
+	HalfExtents mgl64.Vec3
+	Offset      mgl64.Vec3
+}
+
+func (b Box) Bounds(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+	min := mgl64.Vec3{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := min.Mul(-1)
+	for _, c := range b.corners(p) {
+		for i := range c {
+			min[i] = math.Min(min[i], c[i])
+			max[i] = math.Max(max[i], c[i])
+		}
+	}
+	return min, max
+}
+
+func (b Box) GroundPoints(p *PhysicsObject) []mgl64.Vec3 {
+	corners := b.corners(p)
+	return corners[:]
+}
+
+func (b Box) Inertia(mass float64) mgl64.Mat3 {
+	h := b.HalfExtents
+	return mgl64.Diag3(mgl64.Vec3{
+		mass * (h[1]*h[1] + h[2]*h[2]) / 3,
+		mass * (h[0]*h[0] + h[2]*h[2]) / 3,
+		mass * (h[0]*h[0] + h[1]*h[1]) / 3,
+	})
+}
+
+//frame is the box's world space center and rotation
+func (b Box) frame(p *PhysicsObject) (mgl64.Vec3, mgl64.Mat3) {
+	r := p.Orientation.Normalize().Mat4().Mat3()
+	return p.Position.Add(r.Mul3x1(b.Offset)), r
+}
+
+func (b Box) corners(p *PhysicsObject) [8]mgl64.Vec3 {
+	center, r := b.frame(p)
+	corners := [8]mgl64.Vec3{}
+	for i := range corners {
+		local := b.HalfExtents
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<axis) != 0 {
+				local[axis] = -local[axis]
+			}
+		}
+		corners[i] = center.Add(r.Mul3x1(local))
+	}
+	return corners
+}
+
+//NewShape makes a shape from its config name
+//size is the box's edge lengths, or the sphere's diameter in its first element
+func NewShape(kind string, size mgl64.Vec3) (Shape, error) {
+	switch kind {
+	case ShapeBox:
+		return Box{HalfExtents: size.Mul(0.5)}, nil
+	case ShapeSphere:
+		return Sphere{Radius: size[0] / 2}, nil
+	}
+	return nil, fmt.Errorf("unknown shape %q, have %v", kind, ShapeNames)
+}
+
+//NewBody makes a body of a solid shape at pos, bodies with no mass are static
+func NewBody(shape Shape, mass float64, pos mgl64.Vec3, orientation mgl64.Quat) *PhysicsObject {
+	b := PhysicsObject{
+		Position:    pos,
+		Orientation: orientation,
+		Shape:       shape,
+		Mass:        mass,
+		Static:      mass <= 0,
+	}
+	if b.Static {
+		b.InertiaTensor = mgl64.Ident3()
+	} else {
+		b.InertiaTensor = shape.Inertia(mass)
+	}
+	return &b
+}
+
+//AddBody puts a body in the world, ResetPhysics returns it to where it is now
+func (ps *PhysicsSim) AddBody(b *PhysicsObject) {
+	b.start = b.State()
+	b.previous = b.start
+	ps.Bodies = append(ps.Bodies, b)
+}
+
+//GravityForces is the only force on bodies other than the airplane
+func (ps *PhysicsSim) GravityForces(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+	return ps.Gravity.Mul(p.Mass), mgl64.Vec3{}
+}
+
+//Contact is a point where body A touches body B, or the ground when B is nil
+type Contact struct {
+	A, B   *PhysicsObject
+	Point  mgl64.Vec3 //world space
+	Normal mgl64.Vec3 //from B towards A
+	Depth  float64    //m of overlap along the normal
+
+	//Share of the overlap this contact corrects, several contacts often describe the same overlap
+	weight float64
+
+	//Solver state
+	rA, rB         mgl64.Vec3
+	tangents       [2]mgl64.Vec3
+	massN          float64
+	massT          [2]float64
+	targetVelocity float64
+	impulseN       float64
+	impulseT       [2]float64
+}
+
+//flipped swaps the bodies of the contacts
+func flipped(contacts []Contact) []Contact {
+	for i := range contacts {
+		c := &contacts[i]
+		c.A, c.B = c.B, c.A
+		c.Normal = c.Normal.Mul(-1)
+	}
+	return contacts
+}
+
+//GroundContacts finds where a body has gone through the ground
+//Bodies with wheels only touch the ground through their landing gear
+func (ps *PhysicsSim) GroundContacts(b *PhysicsObject) []Contact {
+	if b.Static || len(b.Wheels) > 0 {
+		return nil
+	}
+	points := []mgl64.Vec3{}
+	if len(b.contactPoints) > 0 {
+		for _, p := range b.contactPoints {
+			points = append(points, b.ModelSpaceToWorldSpace(p, b.Position))
+		}
+	} else if b.Shape != nil {
+		points = b.Shape.GroundPoints(b)
+	}
+	//Where each point was before the step, carried along with the body
+	toPrevious := b.previous.Orientation.Mul(b.Orientation.Conjugate())
+	contacts := []Contact{}
+	for _, p := range points {
+		from := b.previous.Position.Add(toPrevious.Rotate(p.Sub(b.Position)))
+		if depth, normal, hit := ps.SweptGroundContact(from, p); hit {
+			contacts = append(contacts, Contact{A: b, Point: p, Normal: normal, Depth: depth})
+		}
+	}
+	return weighted(contacts)
+}
+
+//BroadPhase finds the pairs of bodies whose bounds overlap by sweep and prune along x
+func (ps *PhysicsSim) BroadPhase() [][2]*PhysicsObject {
+	type entry struct {
+		body     *PhysicsObject
+		min, max mgl64.Vec3
+	}
+	entries := make([]entry, 0, len(ps.Bodies))
+	for _, b := range ps.Bodies {
+		if b.Shape == nil {
+			continue
+		}
+		min, max := b.Shape.Bounds(b)
+		entries = append(entries, entry{b, min, max})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].min[0] < entries[j].min[0]
+	})
+
+	pairs := [][2]*PhysicsObject{}
+	for i := range entries {
+		a := &entries[i]
+		for j := i + 1; j < len(entries) && entries[j].min[0] <= a.max[0]; j++ {
+			b := &entries[j]
+			if a.body.Static && b.body.Static {
+				continue
+			}
+			if a.min[1] > b.max[1] || b.min[1] > a.max[1] || a.min[2] > b.max[2] || b.min[2] > a.max[2] {
+				continue
+			}
+			pairs = append(pairs, [2]*PhysicsObject{a.body, b.body})
+		}
+	}
+	return pairs
+}
+
+//Collide generates the contacts between two bodies
+func Collide(a, b *PhysicsObject) []Contact {
+	contacts := []Contact{}
+	switch sa := a.Shape.(type) {
+	case Sphere:
+		switch sb := b.Shape.(type) {
+		case Sphere:
+			contacts = sphereSphere(a, sa, b, sb)
+		case Box:
+			contacts = sphereBox(a, sa, b, sb)
+		}
+	case Box:
+		switch sb := b.Shape.(type) {
+		case Sphere:
+			contacts = flipped(sphereBox(b, sb, a, sa))
+		case Box:
+			contacts = boxBox(a, sa, b, sb)
+		}
+	}
+	return weighted(contacts)
+}
+
+//weighted splits the position correction evenly between contacts found together
+func weighted(contacts []Contact) []Contact {
+	for i := range contacts {
+		contacts[i].weight = 1 / float64(len(contacts))
+	}
+	return contacts
+}
+
+func sphereSphere(a *PhysicsObject, sa Sphere, b *PhysicsObject, sb Sphere) []Contact {
+	d := a.Position.Sub(b.Position)
+	dist := d.Len()
+	if dist >= sa.Radius+sb.Radius {
+		return nil
+	}
+	n := mgl64.Vec3{0, 1, 0}
+	if dist > 1e-9 {
+		n = d.Mul(1 / dist)
+	}
+	return []Contact{{
+		A:      a,
+		B:      b,
+		Point:  b.Position.Add(n.Mul(sb.Radius)),
+		Normal: n,
+		Depth:  sa.Radius + sb.Radius - dist,
+	}}
+}
+
+func sphereBox(a *PhysicsObject, sa Sphere, b *PhysicsObject, sb Box) []Contact {
+	center, r := sb.frame(b)
+	h := sb.HalfExtents
+	local := r.Transpose().Mul3x1(a.Position.Sub(center))
+	closest := mgl64.Vec3{}
+	for i := range local {
+		closest[i] = clamp(local[i], -h[i], h[i])
+	}
+
+	var n mgl64.Vec3
+	var depth float64
+	d := local.Sub(closest)
+	if dist := d.Len(); dist > 1e-9 {
+		if dist >= sa.Radius {
+			return nil
+		}
+		n = d.Mul(1 / dist)
+		depth = sa.Radius - dist
+	} else {
+		//The center is inside the box, push it out through the nearest face
+		axis := 0
+		for i := 1; i < 3; i++ {
+			if h[i]-math.Abs(local[i]) < h[axis]-math.Abs(local[axis]) {
+				axis = i
+			}
+		}
+		sign := math.Copysign(1, local[axis])
+		n[axis] = sign
+		depth = sa.Radius + h[axis] - math.Abs(local[axis])
+		closest[axis] = sign * h[axis]
+	}
+	return []Contact{{
+		A:      a,
+		B:      b,
+		Point:  center.Add(r.Mul3x1(closest)),
+		Normal: r.Mul3x1(n),
+		Depth:  depth,
+	}}
+}
+
+//boxBox finds the axis of least overlap by the separating axis test, then the corners of each box inside the other
+func boxBox(a *PhysicsObject, sa Box, b *PhysicsObject, sb Box) []Contact {
+	ca, ra := sa.frame(a)
+	cb, rb := sb.frame(b)
+	axes := []mgl64.Vec3{}
+	for i := 0; i < 3; i++ {
+		axes = append(axes, ra.Col(i), rb.Col(i))
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if cross := ra.Col(i).Cross(rb.Col(j)); cross.Len() > 1e-6 {
+				axes = append(axes, cross.Normalize())
+			}
+		}
+	}
+	//Half of each box's extent projected onto an axis
+	radius := func(h mgl64.Vec3, r mgl64.Mat3, axis mgl64.Vec3) float64 {
+		return h[0]*math.Abs(r.Col(0).Dot(axis)) + h[1]*math.Abs(r.Col(1).Dot(axis)) + h[2]*math.Abs(r.Col(2).Dot(axis))
+	}
+	between := ca.Sub(cb)
+	depth := math.Inf(1)
+	n := mgl64.Vec3{}
+	for _, axis := range axes {
+		dist := between.Dot(axis)
+		overlap := radius(sa.HalfExtents, ra, axis) + radius(sb.HalfExtents, rb, axis) - math.Abs(dist)
+		if overlap <= 0 {
+			return nil
+		}
+		if overlap < depth {
+			depth = overlap
+			n = axis.Mul(math.Copysign(1, dist))
+		}
+	}
+
+	cornersA, cornersB := sa.corners(a), sb.corners(b)
+	//Support planes of each box along the normal
+	topB, bottomA := math.Inf(-1), math.Inf(1)
+	for i := range cornersA {
+		bottomA = math.Min(bottomA, cornersA[i].Dot(n))
+		topB = math.Max(topB, cornersB[i].Dot(n))
+	}
+
+	contacts := []Contact{}
+	const tolerance = 1e-6
+	for _, c := range cornersA {
+		if inside(c, cb, rb, sb.HalfExtents.Add(mgl64.Vec3{tolerance, tolerance, tolerance})) {
+			contacts = append(contacts, Contact{A: a, B: b, Point: c, Normal: n, Depth: clamp(topB-c.Dot(n), 0, depth)})
+		}
+	}
+	for _, c := range cornersB {
+		if inside(c, ca, ra, sa.HalfExtents.Add(mgl64.Vec3{tolerance, tolerance, tolerance})) {
+			contacts = append(contacts, Contact{A: a, B: b, Point: c, Normal: n, Depth: clamp(c.Dot(n)-bottomA, 0, depth)})
+		}
+	}
+	if len(contacts) == 0 {
+		//Edges crossing, no corner is inside either box
+		mid := ca.Add(cb).Mul(0.5)
+		point := mid.Add(n.Mul((bottomA+topB)/2 - mid.Dot(n)))
+		contacts = append(contacts, Contact{A: a, B: b, Point: point, Normal: n, Depth: depth})
+	}
+	return contacts
+}
+
+//inside checks if p is within the box of half extents h centered at center with rotation r
+func inside(p, center mgl64.Vec3, r mgl64.Mat3, h mgl64.Vec3) bool {
+	local := r.Transpose().Mul3x1(p.Sub(center))
+	return math.Abs(local[0]) <= h[0] && math.Abs(local[1]) <= h[1] && math.Abs(local[2]) <= h[2]
+}
+
+//ResolveCollisions finds every body touching the ground or another body and pushes them apart
+//with sequential impulses, restitution and Coulomb friction
+func (ps *PhysicsSim) ResolveCollisions(dt float64) {
+	contacts := []Contact{}
+	for _, b := range ps.Bodies {
+		contacts = append(contacts, ps.GroundContacts(b)...)
+	}
+	for _, pair := range ps.BroadPhase() {
+		contacts = append(contacts, Collide(pair[0], pair[1])...)
+	}
+	if len(contacts) == 0 {
+		return
+	}
+
+	for i := range contacts {
+		contacts[i].prepare()
+	}
+	for iter := 0; iter < contactIterations; iter++ {
+		for i := range contacts {
+			contacts[i].solve()
+		}
+	}
+	for i := range contacts {
+		contacts[i].correctPosition()
+	}
+}
+
+//prepare works out the effective masses and the bounce of the contact before solving
+func (c *Contact) prepare() {
+	n := c.Normal
+	c.rA = c.Point.Sub(c.A.Position)
+	if c.B != nil {
+		c.rB = c.Point.Sub(c.B.Position)
+	}
+	c.massN = c.effectiveMass(n)
+
+	//Any two directions across the normal
+	t0 := mgl64.Vec3{1, 0, 0}
+	if math.Abs(n[0]) > 0.9 {
+		t0 = mgl64.Vec3{0, 1, 0}
+	}
+	t0 = t0.Sub(n.Mul(t0.Dot(n))).Normalize()
+	c.tangents = [2]mgl64.Vec3{t0, n.Cross(t0)}
+	for i, t := range c.tangents {
+		c.massT[i] = c.effectiveMass(t)
+	}
+
+	c.targetVelocity = 0
+	if vn := c.relativeVelocity().Dot(n); vn < -restingSpeed {
+		c.targetVelocity = -contactRestitution * vn
+	}
+}
+
+//effectiveMass is the mass felt by an impulse along dir at the contact point
+func (c *Contact) effectiveMass(dir mgl64.Vec3) float64 {
+	k := inverseMass(c.A) + c.A.angularResponse(c.rA, dir) + inverseMass(c.B)
+	if c.B != nil {
+		k += c.B.angularResponse(c.rB, dir)
+	}
+	if k <= 0 {
+		return 0
+	}
+	return 1 / k
+}
+
+func (c *Contact) relativeVelocity() mgl64.Vec3 {
+	v := velocityAt(c.A, c.Point)
+	if c.B != nil {
+		v = v.Sub(velocityAt(c.B, c.Point))
+	}
+	return v
+}
+
+//solve applies the impulses keeping the accumulated normal impulse pushing and the friction within the cone
+func (c *Contact) solve() {
+	v := c.relativeVelocity()
+	jn := c.massN * (c.targetVelocity - v.Dot(c.Normal))
+	old := c.impulseN
+	c.impulseN = math.Max(old+jn, 0)
+	c.applyImpulse(c.Normal.Mul(c.impulseN - old))
+
+	limit := contactFriction * c.impulseN
+	for i, t := range c.tangents {
+		v = c.relativeVelocity()
+		jt := -c.massT[i] * v.Dot(t)
+		old := c.impulseT[i]
+		c.impulseT[i] = clamp(old+jt, -limit, limit)
+		c.applyImpulse(t.Mul(c.impulseT[i] - old))
+	}
+}
+
+func (c *Contact) applyImpulse(j mgl64.Vec3) {
+	c.A.applyImpulse(j, c.rA)
+	if c.B != nil {
+		c.B.applyImpulse(j.Mul(-1), c.rB)
+	}
+}
+
+//correctPosition moves the bodies apart by part of the overlap, split by their inverse masses
+func (c *Contact) correctPosition() {
+	depth := (c.Depth - penetrationSlop) * positionCorrection * c.weight
+	imA, imB := inverseMass(c.A), inverseMass(c.B)
+	if depth <= 0 || imA+imB == 0 {
+		return
+	}
+	move := c.Normal.Mul(depth / (imA + imB))
+	if imA > 0 {
+		c.A.Position = c.A.Position.Add(move.Mul(imA))
+	}
+	if imB > 0 {
+		c.B.Position = c.B.Position.Sub(move.Mul(imB))
+	}
+}
+
+func inverseMass(p *PhysicsObject) float64 {
+	if p == nil || p.Static {
+		return 0
+	}
+	return 1 / p.Mass
+}
+
+func velocityAt(p *PhysicsObject, point mgl64.Vec3) mgl64.Vec3 {
+	if p == nil || p.Static {
+		return mgl64.Vec3{}
+	}
+	return p.GetVelocityAtPoint(point)
+}
+
+//angularResponse is how much of an impulse along dir at offset r goes into turning the body
+func (p *PhysicsObject) angularResponse(r, dir mgl64.Vec3) float64 {
+	if p.Static {
+		return 0
+	}
+	return p.InverseInertiaWorld().Mul3x1(r.Cross(dir)).Cross(r).Dot(dir)
+}
+
+func (p *PhysicsObject) applyImpulse(j, r mgl64.Vec3) {
+	if p.Static {
+		return
+	}
+	p.Momentum = p.Momentum.Add(j)
+	p.AngularMomentum = p.AngularMomentum.Add(r.Cross(j))
+}
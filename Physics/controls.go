@@ -0,0 +1,52 @@
+package plane_physics
+
+//Channel names used by the mapping attributes of <animations> and <steering>
+const (
+	ChannelElevator = "ELEVATOR"
+	ChannelAileron  = "AILERON"
+	ChannelRudder   = "RUDDER"
+	ChannelThrottle = "THROTTLE"
+	ChannelFlap     = "FLAP"
+)
+
+//ControlState holds the pilot's control inputs
+//This is the one place the aero model, wheels and animations read control deflections from
+type ControlState struct {
+	Elevator float64 //-1 push to 1 pull (nose up)
+	Aileron  float64 //-1 left to 1 right
+	Rudder   float64 //-1 left to 1 right
+	Throttle float64 //0 to 1
+	Flaps    float64 //0 to 1
+}
+
+//Channel returns the value of a channel by its CRRCSim mapping name
+func (c ControlState) Channel(mapping string) float64 {
+	switch mapping {
+	case ChannelElevator:
+		return c.Elevator
+	case ChannelAileron:
+		return c.Aileron
+	case ChannelRudder:
+		return c.Rudder
+	case ChannelThrottle:
+		return c.Throttle
+	case ChannelFlap:
+		return c.Flaps
+	}
+	return 0
+}
+
+//Clamp limits every channel to its valid range
+func (c *ControlState) Clamp() {
+	c.Elevator = clamp(c.Elevator, -1, 1)
+	c.Aileron = clamp(c.Aileron, -1, 1)
+	c.Rudder = clamp(c.Rudder, -1, 1)
+	c.Throttle = clamp(c.Throttle, 0, 1)
+	c.Flaps = clamp(c.Flaps, 0, 1)
+}
+
+//AeroDeflections converts the stick positions into the CRRCSim elevator, aileron and rudder inputs (-0.5 to 0.5)
+//CRRCSim derivatives treat positive elevator as trailing edge down and positive rudder as trailing edge left
+func (c ControlState) AeroDeflections() (de, da, dr float64) {
+	return -0.5 * c.Elevator, 0.5 * c.Aileron, -0.5 * c.Rudder
+}
@@ -0,0 +1,155 @@
+package plane_physics
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Friction coefficient between a fully braked wheel or skid and the ground
+const groundFriction = 0.8
+
+//Below this sliding speed (m/s) friction is scaled down so resting contacts don't jitter
+const frictionSlipSpeed = 0.05
+
+//Wheel is a spring-damper contact point, from a <wheel> entry of the airplane
+type Wheel struct {
+	Pos            mgl64.Vec3 //model space
+	Spring         SpringSpec
+	PercentBrake   float64
+	CasterAngleRad float64
+	Steering       *SteeringSpec
+
+	//State from the last step
+	Contact     bool
+	Compression float64 //m
+	Force       mgl64.Vec3
+}
+
+//MakeWheels builds the contact points of an airplane
+func MakeWheels(ws WheelSet) []Wheel {
+	wheels := make([]Wheel, len(ws.Wheels))
+	for i, spec := range ws.Wheels {
+		wheels[i] = Wheel{
+			Pos:            BodyToModel(spec.Pos.Vec()),
+			Spring:         spec.Spring,
+			PercentBrake:   spec.PercentBrake,
+			CasterAngleRad: spec.CasterAngleRad,
+			Steering:       spec.Steering,
+		}
+	}
+	return wheels
+}
+
+//GroundContact finds how deep a world space point is below the ground and the ground's normal there
+//The ground is the scenery's triangles once a Terrain is set, and the plane y=0 wherever they don't reach
+func (ps *PhysicsSim) GroundContact(point mgl64.Vec3) (float64, mgl64.Vec3, bool) {
+	if ps.Terrain != nil {
+		return ps.Terrain.Contact(point)
+	}
+	return groundPlaneContact(point)
+}
+
+//SweptGroundContact is GroundContact for a point that moved from from to point during the step
+//so a point that went right through a thin surface in one step still touches it
+func (ps *PhysicsSim) SweptGroundContact(from, point mgl64.Vec3) (float64, mgl64.Vec3, bool) {
+	if ps.Terrain != nil {
+		return ps.Terrain.SweptContact(from, point)
+	}
+	return groundPlaneContact(point)
+}
+
+//groundPlaneContact is GroundContact for the infinite plane y=0
+func groundPlaneContact(point mgl64.Vec3) (float64, mgl64.Vec3, bool) {
+	depth := -point[1]
+	if depth <= 0 {
+		return 0, mgl64.Vec3{}, false
+	}
+	return depth, mgl64.Vec3{0, 1, 0}, true
+}
+
+//GearForces computes the spring, damper and friction forces of every wheel of p in contact with the ground
+//Returns the total force and torque in world space, the wheels' contact state is left alone
+func (ps *PhysicsSim) GearForces(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+	force := mgl64.Vec3{}
+	torque := mgl64.Vec3{}
+	for i := range p.Wheels {
+		worldPos := p.ModelSpaceToWorldSpace(p.Wheels[i].Pos, p.Position)
+		_, _, f := ps.wheelForce(p, &p.Wheels[i], worldPos)
+		force = force.Add(f)
+		torque = torque.Add(worldPos.Sub(p.Position).Cross(f))
+	}
+	return force, torque
+}
+
+//updateWheels stores the contact state of the airplane's wheels after a step
+func (ps *PhysicsSim) updateWheels() {
+	m := ps.Model
+	for i := range m.Wheels {
+		w := &m.Wheels[i]
+		worldPos := m.ModelSpaceToWorldSpace(w.Pos, m.Position)
+		w.Contact, w.Compression, w.Force = ps.wheelForce(m, w, worldPos)
+	}
+}
+
+//wheelForce is the force in world space on a wheel of p at worldPos, and whether and how deep it touches the ground
+func (ps *PhysicsSim) wheelForce(p *PhysicsObject, w *Wheel, worldPos mgl64.Vec3) (bool, float64, mgl64.Vec3) {
+	depth, n, ok := ps.GroundContact(worldPos)
+	if !ok {
+		return false, depth, mgl64.Vec3{}
+	}
+	v := p.GetVelocityAtPoint(worldPos)
+	vn := v.Dot(n)
+
+	normalForce := w.Spring.Constant*depth - w.Spring.Damping*vn
+	if w.Spring.MaxForce > 0 {
+		normalForce = minF(normalForce, w.Spring.MaxForce)
+	}
+	if normalForce <= 0 {
+		return true, depth, mgl64.Vec3{}
+	}
+
+	//Split the sliding velocity into the rolling and sideways directions of the wheel
+	vt := v.Sub(n.Mul(vn))
+	rolling := p.Orientation.Rotate(BodyToModel(w.rollingDirection(ps.Controls)))
+	rolling = rolling.Sub(n.Mul(rolling.Dot(n)))
+	if rolling.Len() < 1e-9 {
+		rolling = vt
+	}
+	if rolling.Len() > 1e-9 {
+		rolling = rolling.Normalize()
+	}
+	side := n.Cross(rolling)
+
+	vRoll := vt.Dot(rolling)
+	vSide := vt.Dot(side)
+	friction := rolling.Mul(-frictionForce(vRoll, w.PercentBrake*groundFriction*normalForce))
+	friction = friction.Add(side.Mul(-frictionForce(vSide, groundFriction*normalForce)))
+
+	return true, depth, n.Mul(normalForce).Add(friction)
+}
+
+//WheelsInContact counts the wheels that touched the ground in the last step
+func (p *PhysicsObject) WheelsInContact() int {
+	n := 0
+	for i := range p.Wheels {
+		if p.Wheels[i].Contact {
+			n++
+		}
+	}
+	return n
+}
+
+//rollingDirection is the direction the wheel rolls in body axes, turned by its caster angle and steering
+func (w *Wheel) rollingDirection(controls ControlState) mgl64.Vec3 {
+	angle := w.CasterAngleRad
+	if w.Steering != nil {
+		angle += controls.Channel(w.Steering.Mapping) * w.Steering.MaxAngle
+	}
+	return mgl64.Vec3{math.Cos(angle), math.Sin(angle), 0}
+}
+
+//frictionForce is coulomb friction of at most maxForce, smoothed near zero speed
+func frictionForce(v, maxForce float64) float64 {
+	return maxForce * clamp(v/frictionSlipSpeed, -1, 1)
+}
@@ -0,0 +1,197 @@
+package plane_physics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//ForceFunc gives the total force and torque in world space acting on an object in its current state
+type ForceFunc func(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3)
+
+//Integrator advances a PhysicsObject by dt seconds under the forces from ForceFunc
+type Integrator interface {
+	Integrate(p *PhysicsObject, dt float64, forces ForceFunc)
+	Name() string
+}
+
+//Names used in the config and GUI
+const (
+	IntegratorSemiImplicitEuler = "semi-implicit-euler"
+	IntegratorRK4               = "rk4"
+	IntegratorRK45              = "rk45"
+)
+
+var IntegratorNames = []string{IntegratorSemiImplicitEuler, IntegratorRK4, IntegratorRK45}
+
+//NewIntegrator makes an integrator from its config name
+func NewIntegrator(name string) (Integrator, error) {
+	switch name {
+	case IntegratorSemiImplicitEuler, "":
+		return SemiImplicitEuler{}, nil
+	case IntegratorRK4:
+		return RK4{}, nil
+	case IntegratorRK45:
+		return NewRK45(), nil
+	}
+	return nil, fmt.Errorf("unknown integrator %q, have %v", name, IntegratorNames)
+}
+
+//SemiImplicitEuler updates momentum first then moves with the new velocity
+//Cheap and keeps oscillators bounded, but only first order
+type SemiImplicitEuler struct{}
+
+func (SemiImplicitEuler) Name() string { return IntegratorSemiImplicitEuler }
+
+func (SemiImplicitEuler) Integrate(p *PhysicsObject, dt float64, forces ForceFunc) {
+	force, torque := forces(p)
+	p.IntegrateLinear(dt, force)
+	p.IntegrateRotational(dt, torque)
+}
+
+//rigidState packs the integrated quantities of a PhysicsObject into one vector
+//position, momentum, orientation (w x y z), angular momentum
+type rigidState [13]float64
+
+func stateOf(p *PhysicsObject) rigidState {
+	s := rigidState{}
+	copy(s[0:3], p.Position[:])
+	copy(s[3:6], p.Momentum[:])
+	s[6] = p.Orientation.W
+	copy(s[7:10], p.Orientation.V[:])
+	copy(s[10:13], p.AngularMomentum[:])
+	return s
+}
+
+func (s *rigidState) applyTo(p *PhysicsObject) {
+	copy(p.Position[:], s[0:3])
+	copy(p.Momentum[:], s[3:6])
+	p.Orientation.W = s[6]
+	copy(p.Orientation.V[:], s[7:10])
+	p.Orientation = p.Orientation.Normalize()
+	copy(p.AngularMomentum[:], s[10:13])
+}
+
+//add returns s + k*d
+func (s rigidState) add(k float64, d rigidState) rigidState {
+	for i := range s {
+		s[i] += k * d[i]
+	}
+	return s
+}
+
+//derivative evaluates the rate of change of every state variable with p set to state s
+func derivative(p *PhysicsObject, s rigidState, forces ForceFunc) rigidState {
+	s.applyTo(p)
+	force, torque := forces(p)
+	vel := p.Velocity()
+	omega := p.AngularVelocity()
+	//spin = 0.5 * w * q
+	spin := mgl64.Quat{W: 0, V: omega}.Mul(p.Orientation).Scale(.5)
+
+	d := rigidState{}
+	copy(d[0:3], vel[:])
+	copy(d[3:6], force[:])
+	d[6] = spin.W
+	copy(d[7:10], spin.V[:])
+	copy(d[10:13], torque[:])
+	return d
+}
+
+//RK4 is the classic fourth order Runge-Kutta method, forces are evaluated four times per step
+type RK4 struct{}
+
+func (RK4) Name() string { return IntegratorRK4 }
+
+func (RK4) Integrate(p *PhysicsObject, dt float64, forces ForceFunc) {
+	y := stateOf(p)
+	k1 := derivative(p, y, forces)
+	k2 := derivative(p, y.add(dt/2, k1), forces)
+	k3 := derivative(p, y.add(dt/2, k2), forces)
+	k4 := derivative(p, y.add(dt, k3), forces)
+	y = y.add(dt/6, k1).add(dt/3, k2).add(dt/3, k3).add(dt/6, k4)
+	y.applyTo(p)
+}
+
+//RK45 is the Dormand-Prince embedded 5(4) method
+//Each call is split into as many sub steps as needed to keep the estimated error under tolerance
+type RK45 struct {
+	AbsTol, RelTol float64
+	MinStep        float64
+
+	//Step size that worked last time, reused as the first guess
+	h float64
+	//Sub steps taken during the last Integrate call
+	LastSteps int
+}
+
+func NewRK45() *RK45 {
+	return &RK45{AbsTol: 1e-9, RelTol: 1e-9, MinStep: 1e-7}
+}
+
+func (*RK45) Name() string { return IntegratorRK45 }
+
+//Dormand-Prince tableau
+var (
+	dpC = [7]float64{0, 1. / 5, 3. / 10, 4. / 5, 8. / 9, 1, 1}
+	dpA = [7][6]float64{
+		{},
+		{1. / 5},
+		{3. / 40, 9. / 40},
+		{44. / 45, -56. / 15, 32. / 9},
+		{19372. / 6561, -25360. / 2187, 64448. / 6561, -212. / 729},
+		{9017. / 3168, -355. / 33, 46732. / 5247, 49. / 176, -5103. / 18656},
+		{35. / 384, 0, 500. / 1113, 125. / 192, -2187. / 6784, 11. / 84},
+	}
+	//Fifth order weights, the same as the last row of dpA
+	dpB = [7]float64{35. / 384, 0, 500. / 1113, 125. / 192, -2187. / 6784, 11. / 84, 0}
+	//Fourth order weights for the error estimate
+	dpBStar = [7]float64{5179. / 57600, 0, 7571. / 16695, 393. / 640, -92097. / 339200, 187. / 2100, 1. / 40}
+)
+
+func (r *RK45) Integrate(p *PhysicsObject, dt float64, forces ForceFunc) {
+	y := stateOf(p)
+	if r.h <= 0 || r.h > dt {
+		r.h = dt
+	}
+	r.LastSteps = 0
+	t := 0.0
+	for t < dt {
+		h := math.Min(r.h, dt-t)
+		k := [7]rigidState{}
+		k[0] = derivative(p, y, forces)
+		for i := 1; i < 7; i++ {
+			yi := y
+			for j := 0; j < i; j++ {
+				yi = yi.add(h*dpA[i][j], k[j])
+			}
+			k[i] = derivative(p, yi, forces)
+		}
+		y5, y4 := y, y
+		for i := 0; i < 7; i++ {
+			y5 = y5.add(h*dpB[i], k[i])
+			y4 = y4.add(h*dpBStar[i], k[i])
+		}
+
+		//Largest error relative to the tolerance over every state variable
+		errRatio := 0.0
+		for i := range y5 {
+			scale := r.AbsTol + r.RelTol*math.Max(math.Abs(y[i]), math.Abs(y5[i]))
+			errRatio = math.Max(errRatio, math.Abs(y5[i]-y4[i])/scale)
+		}
+
+		if errRatio <= 1 || h <= r.MinStep {
+			t += h
+			y = y5
+			r.LastSteps++
+		}
+		//Standard step size controller, growth and shrinkage limited to 5x
+		factor := 5.0
+		if errRatio > 0 {
+			factor = clamp(0.9*math.Pow(errRatio, -0.2), 0.2, 5)
+		}
+		r.h = math.Max(h*factor, r.MinStep)
+	}
+	y.applyTo(p)
+}
@@ -0,0 +1,150 @@
+package plane_physics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Drift allowed after each case's steps, relative where the quantity has a natural scale
+type driftBounds struct {
+	freeFallPos  float64 //m from the analytic position
+	freeFallE    float64 //relative change in total energy
+	spinE        float64 //relative change in rotational energy
+	spinAngle    float64 //rad from the analytic angle about the spin axis
+	springPos    float64 //m from the analytic position
+	springEnergy float64 //relative change in total energy
+}
+
+var integratorCases = []struct {
+	integrator func() Integrator
+	bounds     driftBounds
+}{
+	//First order, free fall lags the parabola by g*dt*t/2
+	{func() Integrator { return SemiImplicitEuler{} }, driftBounds{
+		freeFallPos: 2e-2, freeFallE: 5e-4,
+		spinE: 2e-4, spinAngle: 1e-5,
+		springPos: 2e-3, springEnergy: 5e-3,
+	}},
+	{func() Integrator { return RK4{} }, driftBounds{
+		freeFallPos: 1e-10, freeFallE: 1e-12,
+		spinE: 1e-12, spinAngle: 1e-10,
+		springPos: 1e-9, springEnergy: 1e-10,
+	}},
+	{func() Integrator { return NewRK45() }, driftBounds{
+		freeFallPos: 1e-10, freeFallE: 1e-12,
+		spinE: 1e-12, spinAngle: 1e-10,
+		springPos: 1e-10, springEnergy: 1e-10,
+	}},
+}
+
+const (
+	testDT    = 1e-3
+	testSteps = 2000
+)
+
+func testBody(mass float64, inertia mgl64.Mat3) *PhysicsObject {
+	return &PhysicsObject{Mass: mass, InertiaTensor: inertia, Orientation: mgl64.QuatIdent()}
+}
+
+func run(in Integrator, p *PhysicsObject, forces ForceFunc) {
+	for i := 0; i < testSteps; i++ {
+		in.Integrate(p, testDT, forces)
+	}
+}
+
+func relative(got, want float64) float64 {
+	return math.Abs(got-want) / math.Abs(want)
+}
+
+func TestIntegratorFreeFall(t *testing.T) {
+	gravity := mgl64.Vec3{0, g, 0}
+	for _, c := range integratorCases {
+		in := c.integrator()
+		t.Run(in.Name(), func(t *testing.T) {
+			p := testBody(2, mgl64.Ident3())
+			p.Position = mgl64.Vec3{0, 100, 0}
+			v0 := mgl64.Vec3{3, 4, 0}
+			p.Momentum = v0.Mul(p.Mass)
+			energy := func() float64 { return p.KineticEnergy() - p.Mass*gravity.Dot(p.Position) }
+			e0 := energy()
+
+			run(in, p, func(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+				return gravity.Mul(p.Mass), mgl64.Vec3{}
+			})
+
+			tEnd := testDT * testSteps
+			want := mgl64.Vec3{0, 100, 0}.Add(v0.Mul(tEnd)).Add(gravity.Mul(0.5 * tEnd * tEnd))
+			if d := p.Position.Sub(want).Len(); d > c.bounds.freeFallPos {
+				t.Errorf("position %v is %g m from %v, want under %g", p.Position, d, want, c.bounds.freeFallPos)
+			}
+			if d := relative(energy(), e0); d > c.bounds.freeFallE {
+				t.Errorf("energy drifted by %g, want under %g", d, c.bounds.freeFallE)
+			}
+			if d := p.Momentum.Sub(v0.Add(gravity.Mul(tEnd)).Mul(p.Mass)).Len(); d > 1e-9 {
+				t.Errorf("momentum is %g from m*(v0+g*t)", d)
+			}
+		})
+	}
+}
+
+func TestIntegratorTorqueFreeSpin(t *testing.T) {
+	noForces := func(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) { return mgl64.Vec3{}, mgl64.Vec3{} }
+	inertia := mgl64.Diag3(mgl64.Vec3{1, 2, 3})
+	for _, c := range integratorCases {
+		in := c.integrator()
+		t.Run(in.Name(), func(t *testing.T) {
+			//A tumble about no principal axis, angular momentum and energy are all that stay constant
+			p := testBody(1, inertia)
+			p.AngularMomentum = mgl64.Vec3{0.3, 2, 0.1}
+			l0, e0 := p.AngularMomentum, p.KineticEnergy()
+			run(in, p, noForces)
+			if d := p.AngularMomentum.Sub(l0).Len(); d > 1e-12 {
+				t.Errorf("angular momentum drifted by %g", d)
+			}
+			if d := relative(p.KineticEnergy(), e0); d > c.bounds.spinE {
+				t.Errorf("rotational energy drifted by %g, want under %g", d, c.bounds.spinE)
+			}
+
+			//About a principal axis the body turns at a steady rate
+			p = testBody(1, inertia)
+			omega := 1.5
+			p.AngularMomentum = mgl64.Vec3{0, 0, 3 * omega}
+			run(in, p, noForces)
+			want := math.Remainder(omega*testDT*testSteps, 2*math.Pi)
+			x := p.Orientation.Rotate(mgl64.Vec3{1, 0, 0})
+			got := math.Atan2(x[1], x[0])
+			if d := math.Abs(math.Remainder(got-want, 2*math.Pi)); d > c.bounds.spinAngle {
+				t.Errorf("turned %g rad, want %g within %g", got, want, c.bounds.spinAngle)
+			}
+		})
+	}
+}
+
+func TestIntegratorSpring(t *testing.T) {
+	const k = 50.0
+	for _, c := range integratorCases {
+		in := c.integrator()
+		t.Run(in.Name(), func(t *testing.T) {
+			p := testBody(2, mgl64.Ident3())
+			amplitude := 0.5
+			p.Position = mgl64.Vec3{amplitude, 0, 0}
+			energy := func() float64 { return p.KineticEnergy() + 0.5*k*p.Position.Dot(p.Position) }
+			e0 := energy()
+
+			run(in, p, func(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+				return p.Position.Mul(-k), mgl64.Vec3{}
+			})
+
+			w := math.Sqrt(k / p.Mass)
+			want := amplitude * math.Cos(w*testDT*testSteps)
+			if d := math.Abs(p.Position[0] - want); d > c.bounds.springPos {
+				t.Errorf("position %g is %g m from %g, want under %g", p.Position[0], d, want, c.bounds.springPos)
+			}
+			if d := relative(energy(), e0); d > c.bounds.springEnergy {
+				t.Errorf("energy drifted by %g, want under %g", d, c.bounds.springEnergy)
+			}
+		})
+	}
+}
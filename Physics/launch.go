@@ -0,0 +1,107 @@
+package plane_physics
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Speed used for VelocityRel when there is no airplane to take a trim speed from
+const defaultLaunchSpeed = 10.0 //m/s
+
+//A discus launch throws the glider this many times faster than its trim speed, climbing at salAngle
+const (
+	salSpeedFactor = 3.0
+	salAngle       = 50 * math.Pi / 180
+)
+
+//Clearance (m) left under the lowest wheel for ground starts so the gear settles instead of bouncing
+const groundStartClearance = 0.005
+
+//BuiltinLaunchPresets are offered for every airplane after its own presets
+var BuiltinLaunchPresets = []LaunchPreset{
+	{Name: "Hand launch", Altitude: 1.8, VelocityRel: 1, RelToPlayer: 1, RelRight: 0.6},
+	{Name: "Discus launch (SAL)", Altitude: 1.8, VelocityRel: 1, SAL: 1, RelToPlayer: 1, RelRight: 0.6},
+	{Name: "Runway takeoff", RelToPlayer: 1, RelFront: 6},
+	{Name: "In-air start (50 m)", Altitude: 50, VelocityRel: 1.5, RelToPlayer: 1, RelFront: 40},
+}
+
+//LaunchPresets lists the loaded airplane's presets followed by the built in ones
+func (ps *PhysicsSim) LaunchPresets() []LaunchPreset {
+	presets := []LaunchPreset{}
+	if ps.Airplane != nil {
+		presets = append(presets, ps.Airplane.Launch...)
+	}
+	return append(presets, BuiltinLaunchPresets...)
+}
+
+//LaunchPreset finds a preset by name, an empty or unknown name gives the first one
+func (ps *PhysicsSim) LaunchPreset(name string) LaunchPreset {
+	presets := ps.LaunchPresets()
+	for _, lp := range presets {
+		if lp.Name == name {
+			return lp
+		}
+	}
+	return presets[0]
+}
+
+//Launch resets the simulation and starts the object as the preset describes
+//heading (rad) turns the launch direction about the vertical from +z towards +x, front and right are along and across it
+//Presets relative to the player start from ps.Pilot, the others from the field's origin
+func (ps *PhysicsSim) Launch(lp LaunchPreset, heading float64) {
+	ps.ResetPhysics()
+	m := ps.Model
+
+	turn := mgl64.QuatRotate(heading, mgl64.Vec3{0, 1, 0})
+	forward := turn.Rotate(mgl64.Vec3{0, 0, 1})
+	right := turn.Rotate(mgl64.Vec3{-1, 0, 0})
+	pos := forward.Mul(lp.RelFront).Add(right.Mul(lp.RelRight))
+	if lp.RelToPlayer != 0 {
+		pos = pos.Add(mgl64.Vec3{ps.Pilot[0], 0, ps.Pilot[2]})
+	}
+
+	pitch := lp.Angle
+	speed := lp.VelocityRel * defaultLaunchSpeed
+	if ps.Airplane != nil {
+		rho := ps.Atmosphere.At(lp.Altitude).Density
+		speed = lp.VelocityRel * ps.Airplane.Aero.TrimSpeed(m.Mass, rho)
+	}
+	if lp.SAL != 0 {
+		speed *= salSpeedFactor
+		pitch = math.Max(pitch, salAngle)
+	}
+
+	//Model space nose is +z, a positive rotation about +x would pitch it down
+	m.Orientation = turn.Mul(mgl64.QuatRotate(-pitch, mgl64.Vec3{1, 0, 0}))
+
+	ground := 0.0
+	if ps.Terrain != nil {
+		if h, _, ok := ps.Terrain.Height(pos[0], pos[2]); ok {
+			ground = h
+		}
+	}
+	pos[1] = ground + lp.Altitude
+	if lp.Altitude <= 0 {
+		//Rest the lowest wheel or corner just above the ground
+		pos[1] = ground - ps.lowestPoint() + groundStartClearance
+	}
+	m.Position = pos
+
+	direction := m.Orientation.Rotate(mgl64.Vec3{0, 0, 1})
+	m.Momentum = direction.Mul(speed * m.Mass)
+	m.previous = m.State()
+}
+
+//lowestPoint is the lowest wheel or contact point below the object's origin in its current orientation
+func (ps *PhysicsSim) lowestPoint() float64 {
+	m := ps.Model
+	lowest := 0.0
+	for _, w := range m.Wheels {
+		lowest = math.Min(lowest, m.Orientation.Rotate(w.Pos)[1])
+	}
+	for _, p := range m.contactPoints {
+		lowest = math.Min(lowest, m.Orientation.Rotate(p)[1])
+	}
+	return lowest
+}
@@ -0,0 +1,38 @@
+package plane_physics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+func TestLaunchHeading(t *testing.T) {
+	ps := InitPhysicsContext()
+	ps.Pilot = mgl64.Vec3{10, 1.7, -20}
+	lp := LaunchPreset{Name: "test", Altitude: 5, VelocityRel: 1, RelFront: 40, RelRight: 3}
+	for _, c := range []struct {
+		relToPlayer int
+		heading     float64
+		want        mgl64.Vec3
+	}{
+		{0, 0, mgl64.Vec3{-3, 5, 40}},
+		//Facing +x, right is +z
+		{0, math.Pi / 2, mgl64.Vec3{40, 5, 3}},
+		{1, math.Pi / 2, mgl64.Vec3{50, 5, -17}},
+		{1, math.Pi, mgl64.Vec3{13, 5, -60}},
+	} {
+		lp.RelToPlayer = c.relToPlayer
+		ps.Launch(lp, c.heading)
+		m := ps.Model
+		if m.Position.Sub(c.want).Len() > 1e-9 {
+			t.Errorf("rel_to_player %d, heading %g: launched at %v, want %v", c.relToPlayer, c.heading, m.Position, c.want)
+		}
+		//Flying straight out along the heading
+		direction := m.Velocity().Normalize()
+		want := mgl64.Vec3{math.Sin(c.heading), 0, math.Cos(c.heading)}
+		if direction.Sub(want).Len() > 1e-9 {
+			t.Errorf("rel_to_player %d, heading %g: flying towards %v, want %v", c.relToPlayer, c.heading, direction, want)
+		}
+	}
+}
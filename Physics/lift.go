@@ -0,0 +1,168 @@
+package plane_physics
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//How often (s of simulated time) thermals are moved, born and retired
+const thermalUpdateInterval = 1.0
+
+//Thermal is a column of rising air that drifts with the wind
+type Thermal struct {
+	Base     mgl64.Vec3 //center of the column at the ground, y unused
+	Radius   float64    //m, core radius at the top of the column
+	Strength float64    //m/s, updraft in the middle of the core at full strength
+	Born     float64    //s of simulated time
+	Lifetime float64    //s
+}
+
+//Envelope is how developed the thermal is at time t, from 0 at birth and death to 1 in its prime
+func (th *Thermal) Envelope(t float64) float64 {
+	age := t - th.Born
+	if age <= 0 || age >= th.Lifetime {
+		return 0
+	}
+	//A fifth of the lifetime is spent building up and another fading away
+	ramp := clamp(math.Min(age, th.Lifetime-age)/(0.2*th.Lifetime), 0, 1)
+	return ramp * ramp * (3 - 2*ramp)
+}
+
+//Thermals keeps a population of thermals alive over an area
+//Thermals are born at random places within Area of Center, drift with the wind and die off after their lifetime
+type Thermals struct {
+	Center   mgl64.Vec3
+	Area     float64 //m, radius of the area kept populated
+	Count    int     //number of thermals kept alive
+	Strength float64 //m/s, mean core updraft
+	Radius   float64 //m, mean core radius
+	Lifetime float64 //s, mean lifetime
+	Ceiling  float64 //m, height of the top of the convective layer, no lift above
+	Drift    WindField
+	Seed     int64
+
+	Active []Thermal
+
+	rng     *rand.Rand
+	updated float64 //time the thermals were last moved
+}
+
+func (ts *Thermals) WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3 {
+	ts.advance(t)
+	h := pos[1]
+	if h <= 0 || h >= ts.Ceiling {
+		return mgl64.Vec3{}
+	}
+	//Thermals start weak and narrow at the ground, and fade out near the ceiling
+	rel := h / ts.Ceiling
+	heightFactor := clamp(rel/0.1, 0, 1) * clamp((1-rel)/0.1, 0, 1)
+	widthFactor := math.Max(math.Cbrt(rel), 0.3)
+
+	up := 0.0
+	for i := range ts.Active {
+		th := &ts.Active[i]
+		env := th.Envelope(t)
+		if env == 0 {
+			continue
+		}
+		center := ts.CoreAt(th, h, t)
+		dx, dz := pos[0]-center[0], pos[2]-center[2]
+		radius := th.Radius * widthFactor
+		x2 := (dx*dx + dz*dz) / (radius * radius)
+		if x2 > 25 {
+			continue
+		}
+		//Updraft in the core surrounded by a ring of sink, the net flow through any level is zero
+		up += th.Strength * env * heightFactor * (1 - x2) * math.Exp(-x2)
+	}
+	return mgl64.Vec3{0, up, 0}
+}
+
+//CoreAt is the center of a thermal at height h
+//The column leans downwind by how far the wind carries the air while it rises that high
+func (ts *Thermals) CoreAt(th *Thermal, h, t float64) mgl64.Vec3 {
+	center := mgl64.Vec3{th.Base[0], h, th.Base[2]}
+	if ts.Drift == nil || th.Strength <= 0 {
+		return center
+	}
+	wind := ts.Drift.WindAt(center, t)
+	riseTime := h / th.Strength
+	return center.Add(mgl64.Vec3{wind[0], 0, wind[2]}.Mul(riseTime))
+}
+
+//advance moves the thermals forward to time t, only in whole update intervals
+func (ts *Thermals) advance(t float64) {
+	if ts.rng == nil || t < ts.updated-thermalUpdateInterval {
+		ts.populate(t)
+	}
+	for t >= ts.updated+thermalUpdateInterval {
+		ts.updated += thermalUpdateInterval
+		kept := ts.Active[:0]
+		for _, th := range ts.Active {
+			if ts.Drift != nil {
+				//Carried by the wind halfway up the column
+				wind := ts.Drift.WindAt(mgl64.Vec3{th.Base[0], ts.Ceiling / 2, th.Base[2]}, ts.updated)
+				th.Base = th.Base.Add(mgl64.Vec3{wind[0], 0, wind[2]}.Mul(thermalUpdateInterval))
+			}
+			if ts.updated-th.Born < th.Lifetime {
+				kept = append(kept, th)
+			}
+		}
+		ts.Active = kept
+		for len(ts.Active) < ts.Count {
+			ts.Active = append(ts.Active, ts.spawn(ts.updated))
+		}
+	}
+}
+
+//populate starts a new set of thermals at time t, already at random points in their lives
+func (ts *Thermals) populate(t float64) {
+	ts.rng = rand.New(rand.NewSource(ts.Seed))
+	ts.updated = t
+	ts.Active = ts.Active[:0]
+	for i := 0; i < ts.Count; i++ {
+		th := ts.spawn(t)
+		th.Born -= ts.rng.Float64() * th.Lifetime
+		ts.Active = append(ts.Active, th)
+	}
+}
+
+func (ts *Thermals) spawn(t float64) Thermal {
+	//Uniform over the disk
+	r := ts.Area * math.Sqrt(ts.rng.Float64())
+	angle := ts.rng.Float64() * 2 * math.Pi
+	vary := func(mean float64) float64 {
+		return mean * (0.5 + ts.rng.Float64())
+	}
+	return Thermal{
+		Base:     ts.Center.Add(mgl64.Vec3{r * math.Cos(angle), 0, r * math.Sin(angle)}),
+		Radius:   vary(ts.Radius),
+		Strength: vary(ts.Strength),
+		Born:     t,
+		Lifetime: vary(ts.Lifetime),
+	}
+}
+
+//RidgeLift is the air pushed up where the wind blows onto rising terrain, and down behind it
+type RidgeLift struct {
+	Wind        WindField //the steady wind flowing over the terrain
+	Terrain     *Terrain
+	DecayHeight float64 //m, the lift falls off exponentially with height above the slope
+}
+
+func (rl *RidgeLift) WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3 {
+	if rl.Terrain == nil || rl.Wind == nil {
+		return mgl64.Vec3{}
+	}
+	ground, n, ok := rl.Terrain.Height(pos[0], pos[2])
+	if !ok || pos[1] < ground {
+		return mgl64.Vec3{}
+	}
+	wind := rl.Wind.WindAt(pos, t)
+	//Air following the surface rises by the slope along the wind, cliffs count as a 10:1 slope
+	ny := math.Max(n[1], 0.1)
+	up := -(wind[0]*n[0] + wind[2]*n[2]) / ny
+	return mgl64.Vec3{0, up * math.Exp(-(pos[1]-ground)/rl.DecayHeight), 0}
+}
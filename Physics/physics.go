@@ -1,31 +1,98 @@
 package plane_physics
 
 import (
-	"time"
+	"fmt"
+	"math"
 
 	"github.com/go-gl/mathgl/mgl64"
 )
 
 const g float64 = -9.81 //m/s^2
 
+//Defaults for the fixed step loop
+const (
+	DefaultPhysicsRate = 2000 //steps per simulated second
+	DefaultMaxCatchUp  = 0.25 //s of real time simulated per call at most
+)
+
 type PhysicsSim struct {
-	SumDT         float64
-	PhysicsFrames int
-	Model         *PhysicsObject
-	Gravity       mgl64.Vec3
+	SumDT   float64          //simulated time in s
+	Model   *PhysicsObject   //the airplane, also the first of Bodies
+	Bodies  []*PhysicsObject //everything simulated, static scenery props included
+	Gravity mgl64.Vec3
+	Terrain *Terrain   //solid scenery, nil for an infinite flat ground
+	Pilot   mgl64.Vec3 //where the ground pilot stands, only x and z are used
+
+	Integrator  Integrator
+	PhysicsRate float64 //fixed steps per simulated second
+	MaxCatchUp  float64 //longest real time (s) Advance will catch up on, anything more is dropped
+	TimeScale   float64 //simulated seconds per real second
+
+	accumulator float64
+
+	Airplane       *Airplane
+	AirplaneConfig *AirplaneConfig
+	Aero           AeroState
+	Controls       ControlState
+	Atmosphere     Atmosphere
+	Air            AirProperties //at the object, from the last step
+	Wind           WindField     //nil for still air
+	LocalWind      mgl64.Vec3    //at the object, from the last step
+	Power          *PowerTrain   //nil for gliders
+
+	//Called around every step, for recording and replaying flights and anything else that follows the sim step by step
+	BeforeStep, AfterStep StepHooks
 	//Setting     *bulletphysics.PhysicsObject
 }
 
+//StepHooks are named functions run in the order they were added
+type StepHooks struct {
+	names []string
+	hooks []func(ps *PhysicsSim)
+}
+
+//Add appends a hook, or replaces the one already added with the same name
+func (sh *StepHooks) Add(name string, hook func(ps *PhysicsSim)) {
+	for i, n := range sh.names {
+		if n == name {
+			sh.hooks[i] = hook
+			return
+		}
+	}
+	sh.names = append(sh.names, name)
+	sh.hooks = append(sh.hooks, hook)
+}
+
+//Remove takes out the named hook if there is one
+func (sh *StepHooks) Remove(name string) {
+	for i, n := range sh.names {
+		if n == name {
+			sh.names = append(sh.names[:i], sh.names[i+1:]...)
+			sh.hooks = append(sh.hooks[:i], sh.hooks[i+1:]...)
+			return
+		}
+	}
+}
+
+func (sh *StepHooks) run(ps *PhysicsSim) {
+	for _, hook := range sh.hooks {
+		hook(ps)
+	}
+}
+
 func InitPhysicsContext() *PhysicsSim {
 	p := PhysicsSim{}
-	p.PhysicsFrames = 1000
+	p.PhysicsRate = DefaultPhysicsRate
+	p.MaxCatchUp = DefaultMaxCatchUp
+	p.TimeScale = 1
+	p.Integrator = SemiImplicitEuler{}
 	p.Gravity = mgl64.Vec3{0, g, 0}
 
 	m := PhysicsObject{
 		Position:    [3]float64{0, 1, 0},
 		Momentum:    [3]float64{0, 0, 0},
 		Orientation: mgl64.Quat{},
-		Mass:        0,
+		Mass:        1,
 		contactPoints: []mgl64.Vec3{
 			//Bottom 4
 			{-.5, -.5, -.5}, {-.5, -.5, .5}, {.5, -.5, -.5}, {.5, -.5, .5},
@@ -42,95 +109,161 @@ func InitPhysicsContext() *PhysicsSim {
 
 	m.InertiaTensor = it
 
+	m.Shape = Box{HalfExtents: mgl64.Vec3{.5, .5, .5}}
+
 	p.Model = &m
+	p.AddBody(p.Model)
 
 	p.ResetPhysics()
 	return &p
 }
 
+//LoadAirplane gives the simulated object the mass and inertia of one of the airplane's configs
+func (ps *PhysicsSim) LoadAirplane(a *Airplane, configName string) error {
+	conf, err := a.Config(configName)
+	if err != nil {
+		return err
+	}
+	ps.Airplane = a
+	ps.AirplaneConfig = conf
+	conf.ApplyTo(ps.Model)
+	//The wheels replace the cube's corners as contact points
+	ps.Model.Wheels = MakeWheels(a.Wheels)
+	ps.Model.contactPoints = nil
+	ps.Power = nil
+	if conf.Power != nil {
+		ps.Power, err = NewPowerTrain(conf.Power)
+		if err != nil {
+			return fmt.Errorf("airplane %q config %q: %v", a.Name, conf.Description, err)
+		}
+	}
+	ps.ResetPhysics()
+	return nil
+}
+
 func (ps *PhysicsSim) ResetPhysics() {
-	ps.Model.Mass = 1
 	ps.Model.Momentum = mgl64.Vec3{}
 	ps.Model.Position = mgl64.Vec3{0, 1, 0}
 	ps.Model.Orientation = mgl64.QuatRotate(0, mgl64.Vec3{0, 1, 0})
 	ps.Model.AngularMomentum = mgl64.Vec3{}
+	ps.Model.previous = ps.Model.State()
+	for _, b := range ps.Bodies {
+		if b == ps.Model {
+			continue
+		}
+		b.Position, b.Orientation = b.start.Position, b.start.Orientation
+		b.Momentum, b.AngularMomentum = mgl64.Vec3{}, mgl64.Vec3{}
+		b.previous = b.start
+	}
+	ps.accumulator = 0
+	//Outputs of the last step feed into the next one, start from still air
+	ps.Aero = AeroState{}
+	ps.LocalWind = mgl64.Vec3{}
+	if ps.Power != nil {
+		ps.Power.Reset()
+	}
+}
 
-	s := 1.0
-	it := mgl64.Mat3{
-		1, 0, 0,
-		0, 1, 0,
-		0, 0, 1}.Mul(ps.Model.Mass * (s * s) / 6)
+//BodyState is the part of a PhysicsObject needed to draw it
+type BodyState struct {
+	Position    mgl64.Vec3
+	Orientation mgl64.Quat
+}
 
-	ps.Model.InertiaTensor = it
+//State is the current position and orientation of the object
+func (p *PhysicsObject) State() BodyState {
+	return BodyState{p.Position, p.Orientation}
 }
 
-func (ps *PhysicsSim) DoPhysics(paused bool) {
-	if paused {
-		return
-	}
+//Attitude is the roll, pitch and heading (rad) of the object
+//Heading turns from +z towards +x like launch headings, roll is positive with the right wing down
+func (p *PhysicsObject) Attitude() (roll, pitch, heading float64) {
+	nose := p.Orientation.Rotate(mgl64.Vec3{0, 0, 1})
+	right := p.Orientation.Rotate(mgl64.Vec3{-1, 0, 0})
+	up := p.Orientation.Rotate(mgl64.Vec3{0, 1, 0})
+	pitch = math.Asin(clamp(nose[1], -1, 1))
+	heading = math.Atan2(nose[0], nose[2])
+	roll = math.Atan2(-right[1], up[1])
+	return roll, pitch, heading
+}
+
+//Advance runs as many fixed steps as fit in elapsed seconds of real time scaled by TimeScale
+//Time that doesn't fill a whole step is carried over to the next call
+func (ps *PhysicsSim) Advance(elapsed float64) {
+	elapsed = minF(elapsed, ps.MaxCatchUp)
+	ps.accumulator += elapsed * ps.TimeScale
 
-	frameTime := (16 * time.Millisecond).Seconds() //verbosity good
-	ps.SumDT += frameTime
+	dt := 1 / ps.PhysicsRate
+	for ps.accumulator >= dt {
+		ps.Step(dt)
+		ps.accumulator -= dt
+	}
+}
 
-	dt := frameTime / float64(ps.PhysicsFrames)
-	for i := 0; i < ps.PhysicsFrames; i++ {
-		forces := ps.Gravity
+//Interpolated blends the last two physics states by how far the accumulator is into the next step
+//so drawing between steps doesn't stutter
+func (ps *PhysicsSim) Interpolated() BodyState {
+	return ps.InterpolatedBody(ps.Model)
+}
 
-		ps.Model.IntegrateLinear(dt, forces)
-		lastOrientation := ps.Model.Orientation
-		lastAngularMomentum := ps.Model.AngularMomentum
-		ps.Model.IntegrateRotational(dt, mgl64.Vec3{})
+//InterpolatedBody is Interpolated for any of the bodies
+func (ps *PhysicsSim) InterpolatedBody(b *PhysicsObject) BodyState {
+	alpha := clamp(ps.accumulator*ps.PhysicsRate, 0, 1)
+	cur := b.State()
+	return BodyState{
+		Position:    b.previous.Position.Add(cur.Position.Sub(b.previous.Position).Mul(alpha)),
+		Orientation: mgl64.QuatNlerp(b.previous.Orientation, cur.Orientation, alpha),
+	}
+}
 
-		collisions := ps.DetectCollisions()
-		if len(collisions) == 0 {
-			continue
-		}
-		//If multiple collisions are happening(flat plane on flat plane) collisions can just happen at their center
-		//reaction := collisions[0]
-		//reaction.CollisionNormal = collisions[0].CollisionNormal
-		//for _, r := range collisions {
-		//	reaction.CollisionBodyPosition = reaction.CollisionBodyPosition.Add(r.CollisionBodyPosition)
-		//}
-		//
-		//reaction.CollisionBodyPosition = reaction.CollisionBodyPosition.Mul(1.0 / float64(4))
-		//Do the actual math
-		coeff_restitution := .5
-
-		reactionForce := mgl64.Vec3{}
-		reactionTorque := mgl64.Vec3{}
-
-		for _, reaction := range collisions {
-			e := coeff_restitution
-			v := ps.Model.GetVelocityAtPoint(reaction.CollisionBodyPosition) //ps.Model.Momentum.Mul(1 / ps.Model.Mass)
-			n := reaction.CollisionNormal
-			r := reaction.CollisionBodyPosition //.Sub(ps.Model.Position)
-			inv_mass := 1 / ps.Model.Mass
-			inv_it := ps.Model.InertiaTensor.Inv()
-
-			numerator := (v.Mul(-(1 + e))).Dot(n)
-			denom := (inv_it.Mul3x1(r.Cross(n))).Cross(r).Dot(n) + inv_mass
-
-			impulse := numerator / denom
-
-			dP := n.Mul(impulse)
-			F := dP.Mul(1 / dt)
-			reactionForce = reactionForce.Add(F)
-
-			//ps.Model.AngularMomentum = ps.Model.AngularMomentum.Add(r.Cross(n.Mul(j)))
-
-			reactionTorque = reactionTorque.Add(r.Cross(n.Mul(impulse)).Mul(1 / dt))
+//Step advances the simulation by one step of dt seconds
+func (ps *PhysicsSim) Step(dt float64) {
+	ps.BeforeStep.run(ps)
+	for _, b := range ps.Bodies {
+		b.previous = b.State()
+	}
+	ps.SumDT += dt
+	if ps.Power != nil {
+		m := ps.Model
+		vBody := ModelToBody(m.Orientation.Conjugate().Rotate(m.Velocity().Sub(ps.LocalWind)))
+		ps.Power.Update(dt, ps.Controls.Throttle, vBody, ps.Atmosphere.At(m.Position[1]).Density)
+	}
+	ps.Integrator.Integrate(ps.Model, dt, ps.TotalForces)
+	//Props only fall, the cheapest integrator is plenty
+	for _, b := range ps.Bodies {
+		if b != ps.Model && !b.Static {
+			SemiImplicitEuler{}.Integrate(b, dt, ps.GravityForces)
 		}
-		//ps.Model.IntegrateLinear(dt, reactionForce)
-		ps.Model.Momentum = ps.Model.Momentum.Add(reactionForce.Mul(dt))
-		ps.Model.Position = ps.Model.Position.Add(reactionForce.Mul(dt).Mul(dt / ps.Model.Mass))
-		//ps.Model.IntegrateRotational(dt, reactionTorque)
-		//Do the smae thing as linearly, cant integrate because that adds last position to
-		ps.Model.AngularMomentum = lastAngularMomentum
-		ps.Model.Orientation = lastOrientation
-		ps.Model.IntegrateRotational(dt, reactionTorque)
 	}
+	ps.ResolveCollisions(dt)
+	//Only the accepted state sets what the rest of the sim reads, not the integrator's trial states
+	ps.updateAero()
+	ps.updateWheels()
+	if a, ok := ps.Wind.(AirspeedFollower); ok {
+		a.SetAirspeed(ps.Aero.Airspeed)
+	}
+	ps.AfterStep.run(ps)
+}
+
+//TotalForces sums gravity, aerodynamic, landing gear and propeller forces and torques on p in its current state
+func (ps *PhysicsSim) TotalForces(p *PhysicsObject) (mgl64.Vec3, mgl64.Vec3) {
+	aeroForce, aeroTorque := ps.AeroForces(p)
+	gearForce, gearTorque := ps.GearForces(p)
+	forces := ps.Gravity.Mul(p.Mass).Add(aeroForce).Add(gearForce)
+	torques := aeroTorque.Add(gearTorque)
+	if ps.Power != nil {
+		thrust, thrustTorque := ps.Power.Forces()
+		forces = forces.Add(p.Orientation.Rotate(BodyToModel(thrust)))
+		torques = torques.Add(p.Orientation.Rotate(BodyToModel(thrustTorque)))
+	}
+	return forces, torques
+}
 
+//TotalEnergy is the kinetic plus gravitational potential energy of the object in J
+func (ps *PhysicsSim) TotalEnergy() float64 {
+	return ps.Model.KineticEnergy() - ps.Model.Mass*ps.Gravity.Dot(ps.Model.Position)
 }
+
 func minF(a, b float64) float64 {
 	if a < b {
 		return a
@@ -142,25 +275,6 @@ func transformVector(mat mgl64.Mat3, v mgl64.Vec3) mgl64.Vec3 {
 	return mat.Mul3x1(v)
 }
 
-type CollisionResponse struct {
-	CollisionNormal       mgl64.Vec3
-	CollisionBodyPosition mgl64.Vec3
-}
-
-func (ps *PhysicsSim) DetectCollisions() []CollisionResponse {
-	reactions := []CollisionResponse{}
-	for i := range ps.Model.contactPoints {
-		world_space := ps.Model.ModelSpaceToWorldSpace(ps.Model.contactPoints[i], ps.Model.Position)
-		if world_space[1] < 0 {
-			reactions = append(reactions, CollisionResponse{
-				CollisionNormal:       [3]float64{0, 1, 0},
-				CollisionBodyPosition: ps.Model.contactPoints[i],
-			})
-		}
-	}
-	return reactions
-}
-
 type PhysicsObject struct {
 	Position mgl64.Vec3
 	Momentum mgl64.Vec3
@@ -168,21 +282,54 @@ type PhysicsObject struct {
 	Orientation     mgl64.Quat
 	AngularMomentum mgl64.Vec3
 
-	Mass          float64
+	Mass float64
+	//Inertia tensor in model space, rotated into world space with the orientation when used
 	InertiaTensor mgl64.Mat3
 
+	//Cached inverse of InertiaTensor and the tensor it was computed from
+	inverseInertia mgl64.Mat3
+	inverseFor     mgl64.Mat3
+
 	contactPoints []mgl64.Vec3
+	Wheels        []Wheel
+
+	Shape  Shape //nil for bodies nothing collides with
+	Static bool  //never moves, for scenery props
+
+	//Where ResetPhysics puts the body back, and its state before the last step for render interpolation and swept ground contacts
+	start, previous BodyState
 }
 
 func (p *PhysicsObject) GetVelocityAtPoint(point mgl64.Vec3) mgl64.Vec3 {
-	inverseInertiaTensor := p.InertiaTensor.Inv()
-	angularVelocity := transformVector(inverseInertiaTensor, p.AngularMomentum)
-
-	linearVel := p.Momentum.Mul(1 / p.Mass)
-	vel := linearVel.Add(angularVelocity.Cross(point.Sub(p.Position)))
+	vel := p.Velocity().Add(p.AngularVelocity().Cross(point.Sub(p.Position)))
 	return vel
 }
 
+//KineticEnergy is the translational plus rotational kinetic energy in J
+func (p *PhysicsObject) KineticEnergy() float64 {
+	return 0.5*p.Momentum.Dot(p.Velocity()) + 0.5*p.AngularMomentum.Dot(p.AngularVelocity())
+}
+
+//Velocity is the linear velocity of the center of mass in world space
+func (p *PhysicsObject) Velocity() mgl64.Vec3 {
+	return p.Momentum.Mul(1 / p.Mass)
+}
+
+//AngularVelocity is the angular velocity in world space
+func (p *PhysicsObject) AngularVelocity() mgl64.Vec3 {
+	return transformVector(p.InverseInertiaWorld(), p.AngularMomentum)
+}
+
+//InverseInertiaWorld is the inverse inertia tensor rotated into world space, R*I^-1*R^T
+func (p *PhysicsObject) InverseInertiaWorld() mgl64.Mat3 {
+	if p.inverseFor != p.InertiaTensor {
+		p.inverseInertia = p.InertiaTensor.Inv()
+		p.inverseFor = p.InertiaTensor
+	}
+	r := p.Orientation.Normalize().Mat4().Mat3()
+	return r.Mul3(p.inverseInertia).Mul3(r.Transpose())
+}
+
 func (p *PhysicsObject) IntegrateLinear(dt float64, forces mgl64.Vec3) {
 	//V_{n+1} = V_n + A*dt
 	//A = F/m
@@ -209,9 +356,8 @@ func (p *PhysicsObject) IntegrateRotational(dt float64, torques mgl64.Vec3) {
 
 	p.AngularMomentum = p.AngularMomentum.Add(torques.Mul(dt))
 
-	inverseInertiaTensor := p.InertiaTensor.Inv()
-	angularVel := inverseInertiaTensor.Mul3x1(p.AngularMomentum)
 	p.Orientation = p.Orientation.Normalize()
+	angularVel := p.AngularVelocity()
 
 	//// spin = 0.5 *w* *q*
 	q := mgl64.Quat{
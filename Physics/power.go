@@ -0,0 +1,422 @@
+package plane_physics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Discharge curve used when a battery doesn't give its own, relative open circuit voltage from full to empty
+var defaultDischargeCurve = []float64{1.00, 0.93, 0.91, 0.90, 0.89, 0.88, 0.87, 0.86, 0.85, 0.85, 0.84, 0.83, 0.82, 0.80, 0.78, 0.75, 0.67}
+
+//CRRCSim keeps the batteries and engines airplanes name with filename= in separate files, which aren't shipped
+//Configs using them can't be simulated, the error names the missing file
+
+//Voltage assumed for power trains sized by automagic
+const automagicVoltage = 8.4
+
+//Seconds the voltage has to stay under U_off before the controller cuts off, so the sag of a spin up doesn't trip it
+const cutOffDelay = 0.5
+
+//PowerTrain is every battery of an airplane config with the motors and propellers they drive
+type PowerTrain struct {
+	Batteries []*Battery
+}
+
+//Battery drains through the motors on its shafts
+//Terminal voltage is the open circuit voltage from the discharge curve minus the sag across R_I
+type Battery struct {
+	Capacity    float64 //As
+	U0          float64 //V, open circuit when full
+	UOff        float64 //V, the controller cuts the motor off below this
+	RI          float64 //Ohm
+	ThrottleMin float64 //throttle below this stops the motor
+	Discharge   []float64
+	Shafts      []*Shaft
+
+	//State
+	Used    float64 //As drawn so far
+	Current float64 //A
+	Voltage float64 //V at the terminals
+	CutOff  bool    //the controller has stopped the motor for low voltage
+
+	lowVoltageTime float64 //s the voltage has been under U_off
+}
+
+//Shaft connects motors to propellers, everything on it turns at the same speed
+type Shaft struct {
+	J          float64 //kg*m^2 of the shaft, motors and propellers
+	Brake      bool    //held still when the motors are off
+	Motors     []Motor
+	Propellers []Propeller
+
+	Omega float64 //rad/s
+
+	designOmega float64 //static speed the automagic motor was sized for
+}
+
+//Motor is a brushed or brushless DC motor with a speed controller
+type Motor struct {
+	KM float64 //V*s/rad, also the torque constant in N*m/A
+	R  float64 //Ohm
+	I0 float64 //A of no load current, the motor's friction
+	J  float64 //kg*m^2 of the rotor
+
+	Current float64 //A through the motor
+}
+
+//Propeller turns shaft speed and airspeed into thrust
+//The coefficients are simple fits to model propellers over the advance ratio, set from the pitch to diameter ratio
+type Propeller struct {
+	D, H     float64 //m, diameter and pitch
+	FoldRPS  float64 //rev/s below which the blades fold when unpowered, negative for fixed blades
+	Position mgl64.Vec3
+	Axis     mgl64.Vec3 //thrust direction in body axes
+
+	CT0, CP0    float64 //static thrust and power coefficients
+	J0          float64 //advance ratio of zero thrust
+	ThrustScale float64
+
+	Thrust float64 //N
+	Torque float64 //N*m absorbed
+	Folded bool
+}
+
+//NewPowerTrain builds the power train of an airplane config
+func NewPowerTrain(spec *PowerSpec) (*PowerTrain, error) {
+	pt := PowerTrain{}
+	batteries := spec.Batteries
+	var magic *PowerAutomagic
+	if spec.Automagic != nil {
+		magic = spec.Automagic
+		batteries = append(batteries, spec.Automagic.Batteries...)
+	}
+	for i := range batteries {
+		b, err := newBattery(&batteries[i], magic)
+		if err != nil {
+			return nil, fmt.Errorf("battery %d: %v", i, err)
+		}
+		pt.Batteries = append(pt.Batteries, b)
+	}
+	return &pt, nil
+}
+
+func newBattery(spec *BatterySpec, magic *PowerAutomagic) (*Battery, error) {
+	s := *spec
+	if s.Filename != "" && s.C == 0 {
+		return nil, fmt.Errorf("battery file %q is missing, give C, U_0, U_off and R_I in the airplane instead", s.Filename)
+	}
+	b := Battery{
+		Capacity:    s.C * 3600,
+		U0:          s.U0,
+		UOff:        s.UOff,
+		RI:          s.RI,
+		ThrottleMin: s.ThrottleMin,
+		Discharge:   s.U0Rel,
+	}
+	if len(b.Discharge) == 0 {
+		b.Discharge = defaultDischargeCurve
+	}
+	if s.Automagic != nil {
+		b.U0 = automagicVoltage
+		b.UOff = automagicVoltage * defaultDischargeCurve[len(defaultDischargeCurve)-2]
+	}
+
+	for i := range s.Shafts {
+		sh, err := newShaft(&s.Shafts[i], magic, b.U0)
+		if err != nil {
+			return nil, fmt.Errorf("shaft %d: %v", i, err)
+		}
+		b.Shafts = append(b.Shafts, sh)
+	}
+
+	if s.Automagic != nil {
+		//Enough capacity for T seconds at the static current
+		current := 0.0
+		for _, sh := range b.Shafts {
+			for _, m := range sh.Motors {
+				current += (b.U0 - m.KM*sh.designOmega) / m.R
+			}
+		}
+		b.Capacity = current * s.Automagic.T
+	}
+	if b.Capacity <= 0 || b.U0 <= 0 {
+		return nil, fmt.Errorf("battery needs a capacity and voltage")
+	}
+	return &b, nil
+}
+
+func newShaft(spec *ShaftSpec, magic *PowerAutomagic, voltage float64) (*Shaft, error) {
+	sh := Shaft{J: spec.J, Brake: spec.Brake != 0}
+	for i, ps := range spec.Propellers {
+		p, err := newPropeller(ps)
+		if err != nil {
+			return nil, fmt.Errorf("propeller %d: %v", i, err)
+		}
+		sh.Propellers = append(sh.Propellers, p)
+		sh.J += ps.J
+	}
+	for i, es := range spec.Engines {
+		var m Motor
+		switch {
+		case es.Automagic != nil:
+			m = sh.automagicMotor(es.Automagic, magic, voltage)
+		case es.Filename != "":
+			return nil, fmt.Errorf("engine file %q is missing, only automagic engines can be simulated", es.Filename)
+		default:
+			return nil, fmt.Errorf("engine %d has neither a filename nor automagic", i)
+		}
+		sh.Motors = append(sh.Motors, m)
+		sh.J += m.J
+	}
+	//Many files leave the inertia out, but with none the shaft speed can't be integrated
+	//Use a rough estimate of the propellers' own inertia as the least
+	minJ := 0.0
+	for _, p := range sh.Propellers {
+		minJ += 2e-3 * math.Pow(p.D, 4)
+	}
+	sh.J = math.Max(sh.J, minJ)
+	return &sh, nil
+}
+
+//automagicMotor sizes a motor for the propellers, like CRRCSim's automagic
+//The motor turns the propellers at omega_p when static with efficiency eta at the given voltage
+//F scales the propellers so they give that static thrust there
+func (sh *Shaft) automagicMotor(em *EngineAutomagic, pm *PowerAutomagic, voltage float64) Motor {
+	omega := em.OmegaP
+	n := omega / (2 * math.Pi)
+	torque, thrust := 0.0, 0.0
+	for i := range sh.Propellers {
+		p := &sh.Propellers[i]
+		torque += p.CP0 * seaLevel.Density * n * n * math.Pow(p.D, 5) / (2 * math.Pi)
+		thrust += p.CT0 * seaLevel.Density * n * n * math.Pow(p.D, 4)
+	}
+	if pm != nil && pm.F > 0 && thrust > 0 {
+		for i := range sh.Propellers {
+			sh.Propellers[i].ThrustScale = pm.F / thrust
+		}
+	}
+	sh.designOmega = omega
+
+	m := Motor{}
+	m.KM = em.Eta * voltage / omega
+	current := torque / m.KM
+	m.R = voltage * (1 - em.Eta) / current
+	//Peak efficiency is (1 - sqrt(I0/Istall))^2
+	stall := voltage / m.R
+	m.I0 = stall * math.Pow(1-math.Sqrt(em.EtaOpt), 2)
+	return m
+}
+
+func newPropeller(spec PropellerSpec) (Propeller, error) {
+	//The advance ratio of zero thrust comes from the pitch, a flat propeller would have none
+	if spec.D <= 0 || spec.H <= 0 {
+		return Propeller{}, fmt.Errorf("diameter and pitch must be positive, got D=%v H=%v", spec.D, spec.H)
+	}
+	ratio := spec.H / spec.D
+	p := Propeller{
+		D:           spec.D,
+		H:           spec.H,
+		FoldRPS:     spec.NFold,
+		Axis:        mgl64.Vec3{1, 0, 0},
+		CT0:         0.05 + 0.08*ratio,
+		CP0:         0.02 + 0.075*ratio*ratio,
+		J0:          ratio,
+		ThrustScale: 1,
+	}
+	down, right := spec.DownThrust, 0.0
+	if spec.Pos != nil {
+		p.Position = spec.Pos.Vec()
+		down += spec.Pos.DownThrust
+		right += spec.Pos.RightThrust
+	}
+	//Tilt the thrust line down (body +z) and to the right (body +y)
+	down, right = mgl64.DegToRad(down), mgl64.DegToRad(right)
+	p.Axis = mgl64.Vec3{math.Cos(down) * math.Cos(right), math.Sin(right), math.Sin(down)}
+	return p, nil
+}
+
+//Air at sea level, for sizing automagic power trains
+var seaLevel = (&Atmosphere{}).At(0)
+
+//Reset charges the batteries and stops the motors
+func (pt *PowerTrain) Reset() {
+	for _, b := range pt.Batteries {
+		b.Used, b.Current, b.Voltage, b.CutOff, b.lowVoltageTime = 0, 0, b.OpenCircuitVoltage(), false, 0
+		for _, sh := range b.Shafts {
+			sh.Omega = 0
+		}
+	}
+}
+
+//Update advances the shaft speeds and battery charge by dt
+//vBody is the velocity relative to the air in body axes
+func (pt *PowerTrain) Update(dt, throttle float64, vBody mgl64.Vec3, rho float64) {
+	for _, b := range pt.Batteries {
+		b.update(dt, throttle, vBody, rho)
+	}
+}
+
+//Forces is the total thrust and the reaction torque of the motors in body axes
+func (pt *PowerTrain) Forces() (mgl64.Vec3, mgl64.Vec3) {
+	force, torque := mgl64.Vec3{}, mgl64.Vec3{}
+	for _, b := range pt.Batteries {
+		for _, sh := range b.Shafts {
+			for i := range sh.Propellers {
+				p := &sh.Propellers[i]
+				f := p.Axis.Mul(p.Thrust)
+				force = force.Add(f)
+				torque = torque.Add(p.Position.Cross(f))
+				//The airframe is twisted the opposite way to the propeller
+				torque = torque.Sub(p.Axis.Mul(p.Torque))
+			}
+		}
+	}
+	return force, torque
+}
+
+//StateOfCharge is the fraction of capacity left
+func (b *Battery) StateOfCharge() float64 {
+	return clamp(1-b.Used/b.Capacity, 0, 1)
+}
+
+//OpenCircuitVoltage is the voltage with no load at the current charge
+func (b *Battery) OpenCircuitVoltage() float64 {
+	curve := b.Discharge
+	x := (1 - b.StateOfCharge()) * float64(len(curve)-1)
+	i := int(x)
+	if i >= len(curve)-1 {
+		return b.U0 * curve[len(curve)-1]
+	}
+	frac := x - float64(i)
+	return b.U0 * (curve[i] + (curve[i+1]-curve[i])*frac)
+}
+
+//RPM of the first shaft
+func (b *Battery) RPM() float64 {
+	if len(b.Shafts) == 0 {
+		return 0
+	}
+	return b.Shafts[0].Omega * 60 / (2 * math.Pi)
+}
+
+func (b *Battery) update(dt, throttle float64, vBody mgl64.Vec3, rho float64) {
+	e := b.OpenCircuitVoltage()
+	if throttle < b.ThrottleMin || throttle <= 0 {
+		throttle = 0
+		//The controller rearms once the stick is back at idle and the pack has recovered
+		if e > b.UOff {
+			b.CutOff = false
+		}
+	}
+	if b.CutOff || b.StateOfCharge() <= 0 {
+		throttle = 0
+	}
+
+	total := 0.0
+	for _, sh := range b.Shafts {
+		total += sh.update(dt, throttle, e, b.RI, vBody, rho)
+	}
+	b.Current = total
+	b.Voltage = e - b.RI*total
+	b.Used += total * dt
+	if throttle > 0 && b.Voltage < b.UOff {
+		b.lowVoltageTime += dt
+	} else {
+		b.lowVoltageTime = 0
+	}
+	if b.lowVoltageTime > cutOffDelay {
+		b.CutOff = true
+	}
+}
+
+//update advances the shaft speed, returning the current drawn from the battery
+//The controller chops the battery voltage by the throttle so the battery sees throttle times the motor current
+func (sh *Shaft) update(dt, throttle, e, ri float64, vBody mgl64.Vec3, rho float64) float64 {
+	if throttle == 0 && sh.Brake {
+		sh.Omega = 0
+		for i := range sh.Motors {
+			sh.Motors[i].Current = 0
+		}
+		sh.updatePropellers(0, vBody, rho)
+		return 0
+	}
+
+	netTorque := func(omega float64) float64 {
+		q := -sh.propellerTorque(omega, vBody, rho)
+		for _, m := range sh.Motors {
+			q += m.torque(omega, throttle, e, ri)
+		}
+		return q
+	}
+	//Linearised implicit Euler so light shafts stay stable
+	const h = 1e-3
+	q := netTorque(sh.Omega)
+	dq := (netTorque(sh.Omega+h) - q) / h
+	denom := sh.J - dt*dq
+	if denom < sh.J {
+		denom = sh.J
+	}
+	sh.Omega = math.Max(sh.Omega+dt*q/denom, 0)
+
+	current := 0.0
+	for i := range sh.Motors {
+		m := &sh.Motors[i]
+		m.Current = m.current(sh.Omega, throttle, e, ri)
+		current += throttle * m.Current
+	}
+	sh.updatePropellers(sh.Omega, vBody, rho)
+	return current
+}
+
+func (sh *Shaft) propellerTorque(omega float64, vBody mgl64.Vec3, rho float64) float64 {
+	q := 0.0
+	for i := range sh.Propellers {
+		_, torque := sh.Propellers[i].coefficients(omega/(2*math.Pi), vBody, rho)
+		q += torque
+	}
+	return q
+}
+
+func (sh *Shaft) updatePropellers(omega float64, vBody mgl64.Vec3, rho float64) {
+	n := omega / (2 * math.Pi)
+	for i := range sh.Propellers {
+		p := &sh.Propellers[i]
+		p.Folded = p.FoldRPS >= 0 && n < p.FoldRPS
+		p.Thrust, p.Torque = p.coefficients(n, vBody, rho)
+		if p.Folded {
+			p.Thrust, p.Torque = 0, 0
+		}
+	}
+}
+
+//coefficients gives the thrust and torque at n rev/s
+//Written out in n and V so a stopped propeller is well defined
+func (p *Propeller) coefficients(n float64, vBody mgl64.Vec3, rho float64) (float64, float64) {
+	v := vBody.Dot(p.Axis)
+	//Advance per revolution at zero thrust
+	vZero := p.J0 * p.D
+	thrust := p.ThrustScale * p.CT0 * rho * math.Pow(p.D, 4) * (n*n - n*v/vZero)
+	//Absorbed power falls off with advance, a windmilling propeller drives the shaft
+	power := p.CP0 * rho * math.Pow(p.D, 5) * (n*n - 0.7*v*v/(vZero*vZero))
+	return thrust, power / (2 * math.Pi)
+}
+
+//current through the motor at shaft speed omega, the controller doesn't regenerate
+func (m *Motor) current(omega, throttle, e, ri float64) float64 {
+	if throttle <= 0 {
+		return 0
+	}
+	//Motor voltage is throttle times the sagging battery voltage
+	i := (throttle*e - m.KM*omega) / (m.R + throttle*throttle*ri)
+	return math.Max(i, 0)
+}
+
+func (m *Motor) torque(omega, throttle, e, ri float64) float64 {
+	i := m.current(omega, throttle, e, ri)
+	if omega <= 0 && i <= m.I0 {
+		return 0
+	}
+	return m.KM * (i - m.I0)
+}
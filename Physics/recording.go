@@ -0,0 +1,347 @@
+package plane_physics
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//FlightLogVersion is bumped whenever the layout of flight log files changes
+const FlightLogVersion = 1
+
+var flightLogMagic = [8]byte{'G', 'O', 'F', 'L', 'Y', 'L', 'O', 'G'}
+
+//Longest airplane path, config or settings a log may hold, anything bigger is a broken file
+const maxFlightLogField = 1 << 20
+
+//What a frame holds besides the body states
+const (
+	frameControls = 1 << iota //the controls changed since the last frame
+	frameGust                 //a gust started with the step
+)
+
+//FlightLogHeader describes the simulation a flight was recorded in
+type FlightLogHeader struct {
+	Version        uint16
+	Aircraft       string //path of the airplane description
+	AirplaneConfig string
+	Seed           int64
+	PhysicsRate    float64
+	Bodies         int    //bodies with a state in every frame, the airplane first then the moving props
+	Settings       []byte //whatever else the application needs to set the world up again, opaque here
+}
+
+//FlightFrame is the world after one physics step
+//The first frame of a log is the state at the launch, before any step
+type FlightFrame struct {
+	Controls ControlState //used for the step
+	Gust     *Gust        //started at the beginning of the step
+	States   []RecordedState
+}
+
+//RecordedState is a body's position, momentum, orientation and angular momentum stored as float32s
+type RecordedState [13]float32
+
+//RecordState packs the state of a body
+func RecordState(p *PhysicsObject) RecordedState {
+	s := RecordedState{}
+	for i, v := range stateOf(p) {
+		s[i] = float32(v)
+	}
+	return s
+}
+
+//BodyState is the recorded position and orientation
+func (s *RecordedState) BodyState() BodyState {
+	return BodyState{
+		Position:    mgl64.Vec3{float64(s[0]), float64(s[1]), float64(s[2])},
+		Orientation: mgl64.Quat{W: float64(s[6]), V: mgl64.Vec3{float64(s[7]), float64(s[8]), float64(s[9])}}.Normalize(),
+	}
+}
+
+//FlightLog is a recorded flight
+type FlightLog struct {
+	Header FlightLogHeader
+	Frames []FlightFrame
+}
+
+//Duration is how long the flight lasted in s
+func (fl *FlightLog) Duration() float64 {
+	if len(fl.Frames) == 0 {
+		return 0
+	}
+	return float64(len(fl.Frames)-1) / fl.Header.PhysicsRate
+}
+
+//FrameAt finds the frame at or just before t and how far t is towards the next one
+func (fl *FlightLog) FrameAt(t float64) (int, float64) {
+	pos := clamp(t*fl.Header.PhysicsRate, 0, float64(len(fl.Frames)-1))
+	i := int(pos)
+	return i, pos - float64(i)
+}
+
+//StateAt is the state of one of the recorded bodies at t, interpolated between frames
+func (fl *FlightLog) StateAt(t float64, body int) BodyState {
+	i, alpha := fl.FrameAt(t)
+	a := fl.Frames[i].States[body].BodyState()
+	if i+1 >= len(fl.Frames) {
+		return a
+	}
+	b := fl.Frames[i+1].States[body].BodyState()
+	return BodyState{
+		Position:    a.Position.Add(b.Position.Sub(a.Position).Mul(alpha)),
+		Orientation: mgl64.QuatNlerp(a.Orientation, b.Orientation, alpha),
+	}
+}
+
+//recordedBodies are the bodies whose states are logged, static ones never change
+func (ps *PhysicsSim) recordedBodies() []*PhysicsObject {
+	bodies := []*PhysicsObject{}
+	for _, b := range ps.Bodies {
+		if !b.Static {
+			bodies = append(bodies, b)
+		}
+	}
+	return bodies
+}
+
+func (ps *PhysicsSim) recordFrame(controls ControlState, gust *Gust) FlightFrame {
+	frame := FlightFrame{Controls: controls, Gust: gust}
+	for _, b := range ps.recordedBodies() {
+		frame.States = append(frame.States, RecordState(b))
+	}
+	return frame
+}
+
+//Recorder logs every step of a simulation, add its AfterStep to the sim's AfterStep hooks
+type Recorder struct {
+	Log *FlightLog
+
+	pendingGust *Gust
+}
+
+//NewRecorder starts a log with the simulation's current state as its first frame
+//The version, physics rate, body count and airplane config are filled in from the simulation, the rest of the header is the caller's
+func NewRecorder(ps *PhysicsSim, header FlightLogHeader) *Recorder {
+	header.Version = FlightLogVersion
+	header.PhysicsRate = ps.PhysicsRate
+	header.Bodies = len(ps.recordedBodies())
+	if ps.AirplaneConfig != nil && header.AirplaneConfig == "" {
+		header.AirplaneConfig = ps.AirplaneConfig.Description
+	}
+	r := Recorder{Log: &FlightLog{Header: header}}
+	r.Log.Frames = append(r.Log.Frames, ps.recordFrame(ps.Controls, nil))
+	return &r
+}
+
+//AddGust notes a gust that starts with the next step
+func (r *Recorder) AddGust(g Gust) {
+	r.pendingGust = &g
+}
+
+//AfterStep records the step that was just taken
+func (r *Recorder) AfterStep(ps *PhysicsSim) {
+	r.Log.Frames = append(r.Log.Frames, ps.recordFrame(ps.Controls, r.pendingGust))
+	r.pendingGust = nil
+}
+
+//Resimulation drives a simulation with a log's inputs and checks it follows the recorded trajectory
+//The simulation has to be set up exactly as it was when the recording started
+type Resimulation struct {
+	Log    *FlightLog
+	Frame  int        //last frame reached
+	OnGust func(Gust) //starts a recorded gust, gusts live outside the physics
+
+	MaxError float64 //m, largest distance between a recorded and re-simulated position
+	Diverged int     //first frame whose state differs from the recording, -1 while they all match
+}
+
+func NewResimulation(log *FlightLog, onGust func(Gust)) *Resimulation {
+	return &Resimulation{Log: log, OnGust: onGust, Diverged: -1}
+}
+
+//Done is true once every recorded step has been replayed
+func (rs *Resimulation) Done() bool {
+	return rs.Frame >= len(rs.Log.Frames)-1
+}
+
+//BeforeStep applies the inputs of the next step
+func (rs *Resimulation) BeforeStep(ps *PhysicsSim) {
+	if rs.Done() {
+		return
+	}
+	frame := &rs.Log.Frames[rs.Frame+1]
+	ps.Controls = frame.Controls
+	if frame.Gust != nil && rs.OnGust != nil {
+		g := *frame.Gust
+		g.Start = ps.SumDT
+		rs.OnGust(g)
+	}
+}
+
+//AfterStep compares the new state with the recorded one
+func (rs *Resimulation) AfterStep(ps *PhysicsSim) {
+	if rs.Done() {
+		return
+	}
+	rs.Frame++
+	frame := &rs.Log.Frames[rs.Frame]
+	for i, b := range ps.recordedBodies() {
+		if i >= len(frame.States) {
+			break
+		}
+		now := RecordState(b)
+		if now != frame.States[i] && rs.Diverged < 0 {
+			rs.Diverged = rs.Frame
+		}
+		recorded := frame.States[i].BodyState().Position
+		rs.MaxError = math.Max(rs.MaxError, recorded.Sub(now.BodyState().Position).Len())
+	}
+}
+
+//Write saves the log gzip compressed
+func (fl *FlightLog) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	h := &fl.Header
+	write := func(v interface{}) {
+		//Errors are sticky in the bufio.Writer and show up on Flush
+		binary.Write(bw, binary.LittleEndian, v)
+	}
+	writeBytes := func(b []byte) {
+		write(uint32(len(b)))
+		bw.Write(b)
+	}
+	write(flightLogMagic)
+	write(h.Version)
+	writeBytes([]byte(h.Aircraft))
+	writeBytes([]byte(h.AirplaneConfig))
+	write(h.Seed)
+	write(h.PhysicsRate)
+	write(uint16(h.Bodies))
+	writeBytes(h.Settings)
+
+	last := ControlState{}
+	for i, f := range fl.Frames {
+		var flags uint8
+		if i == 0 || f.Controls != last {
+			flags |= frameControls
+		}
+		if f.Gust != nil {
+			flags |= frameGust
+		}
+		write(flags)
+		if flags&frameControls != 0 {
+			write([5]float64{f.Controls.Elevator, f.Controls.Aileron, f.Controls.Rudder, f.Controls.Throttle, f.Controls.Flaps})
+			last = f.Controls
+		}
+		if f.Gust != nil {
+			write([4]float64{f.Gust.Duration, f.Gust.Peak[0], f.Gust.Peak[1], f.Gust.Peak[2]})
+		}
+		if len(f.States) != h.Bodies {
+			return fmt.Errorf("flight log frame %d has %d body states, the header says %d", i, len(f.States), h.Bodies)
+		}
+		write(f.States)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+//ReadFlightLog loads a log saved by Write
+func ReadFlightLog(r io.Reader) (*FlightLog, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("flight log: %v", err)
+	}
+	br := bufio.NewReader(zr)
+	read := func(v interface{}) error {
+		return binary.Read(br, binary.LittleEndian, v)
+	}
+	readBytes := func() ([]byte, error) {
+		var n uint32
+		if err := read(&n); err != nil {
+			return nil, err
+		}
+		if n > maxFlightLogField {
+			return nil, fmt.Errorf("%d byte field, at most %d are allowed", n, maxFlightLogField)
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(br, b)
+		return b, err
+	}
+
+	fl := FlightLog{}
+	h := &fl.Header
+	var magic [8]byte
+	if err := read(&magic); err != nil || magic != flightLogMagic {
+		return nil, fmt.Errorf("flight log: not a flight log")
+	}
+	if err := read(&h.Version); err != nil {
+		return nil, fmt.Errorf("flight log header: %v", err)
+	}
+	if h.Version != FlightLogVersion {
+		return nil, fmt.Errorf("flight log: version %d, can only read version %d", h.Version, FlightLogVersion)
+	}
+	aircraft, err := readBytes()
+	if err != nil {
+		return nil, fmt.Errorf("flight log header: %v", err)
+	}
+	config, err := readBytes()
+	if err != nil {
+		return nil, fmt.Errorf("flight log header: %v", err)
+	}
+	h.Aircraft, h.AirplaneConfig = string(aircraft), string(config)
+	var bodies uint16
+	for _, v := range []interface{}{&h.Seed, &h.PhysicsRate, &bodies} {
+		if err := read(v); err != nil {
+			return nil, fmt.Errorf("flight log header: %v", err)
+		}
+	}
+	h.Bodies = int(bodies)
+	if h.Settings, err = readBytes(); err != nil {
+		return nil, fmt.Errorf("flight log header: %v", err)
+	}
+
+	controls := ControlState{}
+	for {
+		var flags uint8
+		err := read(&flags)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("flight log frame %d: %v", len(fl.Frames), err)
+		}
+		if flags&frameControls != 0 {
+			var c [5]float64
+			if err := read(&c); err != nil {
+				return nil, fmt.Errorf("flight log frame %d: %v", len(fl.Frames), err)
+			}
+			controls = ControlState{Elevator: c[0], Aileron: c[1], Rudder: c[2], Throttle: c[3], Flaps: c[4]}
+		}
+		f := FlightFrame{Controls: controls, States: make([]RecordedState, h.Bodies)}
+		if flags&frameGust != 0 {
+			var g [4]float64
+			if err := read(&g); err != nil {
+				return nil, fmt.Errorf("flight log frame %d: %v", len(fl.Frames), err)
+			}
+			f.Gust = &Gust{Duration: g[0], Peak: mgl64.Vec3{g[1], g[2], g[3]}}
+		}
+		if err := read(f.States); err != nil {
+			return nil, fmt.Errorf("flight log frame %d: %v", len(fl.Frames), err)
+		}
+		fl.Frames = append(fl.Frames, f)
+	}
+	if len(fl.Frames) == 0 {
+		return nil, fmt.Errorf("flight log: no frames")
+	}
+	return &fl, nil
+}
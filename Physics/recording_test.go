@@ -0,0 +1,113 @@
+package plane_physics
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+const recordedSteps = 600
+
+//recordingSim is the allegro in turbulent wind with a box dropped next to it, the same every time it's made
+func recordingSim(t *testing.T) (*PhysicsSim, *Gusts) {
+	ps := InitPhysicsContext()
+	a, err := LoadAirplane("../Assets/Planes/allegro.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.LoadAirplane(a, ""); err != nil {
+		t.Fatal(err)
+	}
+	ps.AddBody(NewBody(Box{HalfExtents: mgl64.Vec3{0.2, 0.2, 0.2}}, 1, mgl64.Vec3{3, 0.4, 3}, mgl64.QuatIdent()))
+	gusts := &Gusts{}
+	ps.Wind = WindFields{
+		&BoundaryLayer{SteadyWind: SteadyWind{Speed: 3, Direction: 0.5}, RefHeight: 10, Roughness: 0.03},
+		&Turbulence{Model: TurbulenceDryden, W20: 5, Seed: 7},
+		gusts,
+	}
+	ps.Launch(ps.LaunchPreset("In-air start (50 m)"), 0.3)
+	return ps, gusts
+}
+
+func TestRecordingResimulates(t *testing.T) {
+	ps, gusts := recordingSim(t)
+	rec := NewRecorder(ps, FlightLogHeader{Aircraft: "allegro.xml", Seed: 7, Settings: []byte(`{"test":true}`)})
+	ps.AfterStep.Add("recorder", rec.AfterStep)
+	dt := 1 / ps.PhysicsRate
+	for i := 0; i < recordedSteps; i++ {
+		if i%100 == 50 {
+			ps.Controls = ControlState{Elevator: 0.3, Aileron: -0.5, Rudder: 0.2, Throttle: 1, Flaps: 0.1}
+		} else if i%100 == 0 {
+			ps.Controls = ControlState{Elevator: -0.2, Aileron: 0.4}
+		}
+		if i == 200 {
+			g := Gust{Start: ps.SumDT, Duration: 0.1, Peak: mgl64.Vec3{1, 4, -2}}
+			gusts.Add(g)
+			rec.AddGust(g)
+		}
+		ps.Step(dt)
+	}
+	if len(rec.Log.Frames) != recordedSteps+1 {
+		t.Fatalf("recorded %d frames, want %d", len(rec.Log.Frames), recordedSteps+1)
+	}
+	if rec.Log.Header.Bodies != 2 {
+		t.Errorf("recorded %d bodies, want the airplane and the box", rec.Log.Header.Bodies)
+	}
+
+	buf := bytes.Buffer{}
+	if err := rec.Log.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fl, err := ReadFlightLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fl.Header, rec.Log.Header) {
+		t.Errorf("header read back as %+v, want %+v", fl.Header, rec.Log.Header)
+	}
+	if len(fl.Frames) != len(rec.Log.Frames) {
+		t.Fatalf("read %d frames, want %d", len(fl.Frames), len(rec.Log.Frames))
+	}
+	for i, f := range fl.Frames {
+		want := rec.Log.Frames[i]
+		if want.Gust != nil {
+			//When a gust starts is the step it's logged with, the time itself isn't saved
+			g := *want.Gust
+			g.Start = 0
+			want.Gust = &g
+		}
+		if !reflect.DeepEqual(f, want) {
+			t.Fatalf("frame %d read back as %+v, want %+v", i, f, want)
+		}
+	}
+
+	replay, replayGusts := recordingSim(t)
+	rs := NewResimulation(fl, func(g Gust) { replayGusts.Add(g) })
+	replay.BeforeStep.Add("resim", rs.BeforeStep)
+	replay.AfterStep.Add("resim", rs.AfterStep)
+	for i := 0; i < recordedSteps && !rs.Done(); i++ {
+		replay.Step(dt)
+	}
+	if !rs.Done() || rs.Frame != recordedSteps {
+		t.Fatalf("replayed %d steps, want %d", rs.Frame, recordedSteps)
+	}
+	if rs.Diverged != -1 {
+		t.Errorf("diverged at step %d, off by up to %g m", rs.Diverged, rs.MaxError)
+	}
+}
+
+func TestReadFlightLogLongField(t *testing.T) {
+	fl := FlightLog{
+		Header: FlightLogHeader{Version: FlightLogVersion, PhysicsRate: 100, Settings: make([]byte, maxFlightLogField+1)},
+		Frames: []FlightFrame{{}},
+	}
+	buf := bytes.Buffer{}
+	if err := fl.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFlightLog(&buf); err == nil {
+		t.Error("read a log with a field over the limit")
+	}
+}
@@ -0,0 +1,334 @@
+package plane_physics
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Points further than this (m) behind a triangle's surface are not considered in contact with it
+//so the inside of thin objects and the space under roofs doesn't push things around
+const maxPenetration = 0.5
+
+//Smallest size (m) of the cells of the terrain grid, and the most cells along each axis
+const (
+	terrainCellSize     = 5.0
+	maxTerrainCellsAxis = 256
+)
+
+//Triangle is one face of the terrain in world space
+type Triangle struct {
+	A, B, C mgl64.Vec3
+	Normal  mgl64.Vec3
+}
+
+//Terrain is a static triangle mesh, usually the scenery, stored in a uniform grid over the x-z plane
+type Terrain struct {
+	Triangles []Triangle
+
+	minX, minZ   float64
+	cellSize     float64
+	cellsX       int
+	cellsZ       int
+	cells        [][]int32 //triangle indices overlapping each cell
+	outsideCells []int32   //triangles too large to be worth storing per cell
+}
+
+//NewTerrain builds the acceleration grid for a set of triangles
+func NewTerrain(tris [][3]mgl64.Vec3) *Terrain {
+	t := Terrain{}
+	for _, tri := range tris {
+		n := tri[1].Sub(tri[0]).Cross(tri[2].Sub(tri[0]))
+		if n.Len() < 1e-12 {
+			//Degenerate, has no surface to collide with
+			continue
+		}
+		n = n.Normalize()
+		//Mostly horizontal faces are ground, make sure they face up whichever way they were wound
+		if n[1] < -0.3 {
+			n = n.Mul(-1)
+		}
+		t.Triangles = append(t.Triangles, Triangle{tri[0], tri[1], tri[2], n})
+	}
+	t.orientWalls()
+	if len(t.Triangles) == 0 {
+		return &t
+	}
+
+	minX, minZ := math.Inf(1), math.Inf(1)
+	maxX, maxZ := math.Inf(-1), math.Inf(-1)
+	for _, tri := range t.Triangles {
+		lo, hi := tri.boundsXZ()
+		minX, minZ = math.Min(minX, lo[0]), math.Min(minZ, lo[1])
+		maxX, maxZ = math.Max(maxX, hi[0]), math.Max(maxZ, hi[1])
+	}
+	t.minX, t.minZ = minX, minZ
+	t.cellSize = math.Max(terrainCellSize, math.Max(maxX-minX, maxZ-minZ)/maxTerrainCellsAxis)
+	t.cellsX = int((maxX-minX)/t.cellSize) + 1
+	t.cellsZ = int((maxZ-minZ)/t.cellSize) + 1
+	t.cells = make([][]int32, t.cellsX*t.cellsZ)
+
+	//A triangle covering more cells than this goes in the list checked for every query instead
+	const maxCellsPerTriangle = 1024
+	for i, tri := range t.Triangles {
+		lo, hi := tri.boundsXZ()
+		x0, z0 := t.cellOf(lo[0], lo[1])
+		x1, z1 := t.cellOf(hi[0], hi[1])
+		if (x1-x0+1)*(z1-z0+1) > maxCellsPerTriangle {
+			t.outsideCells = append(t.outsideCells, int32(i))
+			continue
+		}
+		for x := x0; x <= x1; x++ {
+			for z := z0; z <= z1; z++ {
+				c := x + z*t.cellsX
+				t.cells[c] = append(t.cells[c], int32(i))
+			}
+		}
+	}
+	return &t
+}
+
+//orientWalls points every connected set of steep faces away from its middle
+//so the walls of a building push outwards whichever way they were wound
+//Flatter faces are left out so walls standing on the ground aren't joined to it
+func (t *Terrain) orientWalls() {
+	steep := func(tri *Triangle) bool {
+		return math.Abs(tri.Normal[1]) <= 0.3
+	}
+	//Steep triangles sharing a vertex belong to the same wall
+	parent := make([]int, len(t.Triangles))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	owner := map[[3]int64]int{}
+	for i, tri := range t.Triangles {
+		if !steep(&tri) {
+			continue
+		}
+		for _, v := range [3]mgl64.Vec3{tri.A, tri.B, tri.C} {
+			//Vertices closer than 0.1 mm are the same
+			key := [3]int64{int64(math.Round(v[0] * 1e4)), int64(math.Round(v[1] * 1e4)), int64(math.Round(v[2] * 1e4))}
+			if j, ok := owner[key]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				owner[key] = i
+			}
+		}
+	}
+
+	sums := map[int]mgl64.Vec3{}
+	counts := map[int]float64{}
+	for i := range t.Triangles {
+		if !steep(&t.Triangles[i]) {
+			continue
+		}
+		root := find(i)
+		sums[root] = sums[root].Add(t.Triangles[i].center())
+		counts[root]++
+	}
+	for i := range t.Triangles {
+		tri := &t.Triangles[i]
+		if !steep(tri) {
+			continue
+		}
+		root := find(i)
+		middle := sums[root].Mul(1 / counts[root])
+		if tri.Normal.Dot(tri.center().Sub(middle)) < 0 {
+			tri.Normal = tri.Normal.Mul(-1)
+		}
+	}
+}
+
+func (tri *Triangle) center() mgl64.Vec3 {
+	return tri.A.Add(tri.B).Add(tri.C).Mul(1.0 / 3)
+}
+
+//cellOf finds the grid cell containing x,z, clamped to the grid
+func (t *Terrain) cellOf(x, z float64) (int, int) {
+	cx := int((x - t.minX) / t.cellSize)
+	cz := int((z - t.minZ) / t.cellSize)
+	if cx < 0 {
+		cx = 0
+	} else if cx >= t.cellsX {
+		cx = t.cellsX - 1
+	}
+	if cz < 0 {
+		cz = 0
+	} else if cz >= t.cellsZ {
+		cz = t.cellsZ - 1
+	}
+	return cx, cz
+}
+
+//Contact finds the surface a point has gone through
+//Returns how far behind the surface the point is along the triangle's normal, and that normal
+//Where the mesh doesn't reach the ground is the plane y=0
+func (t *Terrain) Contact(p mgl64.Vec3) (float64, mgl64.Vec3, bool) {
+	if len(t.Triangles) == 0 {
+		return groundPlaneContact(p)
+	}
+	best := math.Inf(1)
+	bestNormal := mgl64.Vec3{}
+	check := func(indices []int32) {
+		for _, i := range indices {
+			depth, ok := t.Triangles[i].penetration(p)
+			if ok && depth < best {
+				best = depth
+				bestNormal = t.Triangles[i].Normal
+			}
+		}
+	}
+	//Points outside the grid can't touch anything stored in it
+	if p[0] >= t.minX && p[2] >= t.minZ {
+		cx, cz := t.cellOf(p[0], p[2])
+		check(t.cells[cx+cz*t.cellsX])
+	}
+	check(t.outsideCells)
+	if !math.IsInf(best, 1) {
+		return best, bestNormal, true
+	}
+	if _, _, ok := t.surfaceHeight(p[0], p[2]); !ok {
+		return groundPlaneContact(p)
+	}
+	return 0, mgl64.Vec3{}, false
+}
+
+//SweptContact is Contact for a point that moved from from to p
+//When p is too far behind every surface to touch one, the first surface the path went through is used,
+//so a fast body can't pass right through the terrain in one step
+func (t *Terrain) SweptContact(from, p mgl64.Vec3) (float64, mgl64.Vec3, bool) {
+	if depth, normal, ok := t.Contact(p); ok || len(t.Triangles) == 0 {
+		return depth, normal, ok
+	}
+	first := math.Inf(1)
+	depth, normal := 0.0, mgl64.Vec3{}
+	check := func(indices []int32) {
+		for _, i := range indices {
+			tri := &t.Triangles[i]
+			if frac, d, ok := tri.crossing(from, p); ok && frac < first {
+				first, depth, normal = frac, d, tri.Normal
+			}
+		}
+	}
+	//Every cell the path's box overlaps, paths outside the grid can't cross anything stored in it
+	lo := [2]float64{math.Min(from[0], p[0]), math.Min(from[2], p[2])}
+	hi := [2]float64{math.Max(from[0], p[0]), math.Max(from[2], p[2])}
+	if hi[0] >= t.minX && hi[1] >= t.minZ {
+		x0, z0 := t.cellOf(lo[0], lo[1])
+		x1, z1 := t.cellOf(hi[0], hi[1])
+		for x := x0; x <= x1; x++ {
+			for z := z0; z <= z1; z++ {
+				check(t.cells[x+z*t.cellsX])
+			}
+		}
+	}
+	check(t.outsideCells)
+	if math.IsInf(first, 1) {
+		return 0, mgl64.Vec3{}, false
+	}
+	return depth, normal, true
+}
+
+//Height finds the highest surface straight below or above x,z and its normal
+//Outside the mesh the ground is the plane y=0
+func (t *Terrain) Height(x, z float64) (float64, mgl64.Vec3, bool) {
+	if h, n, ok := t.surfaceHeight(x, z); ok {
+		return h, n, true
+	}
+	return 0, mgl64.Vec3{0, 1, 0}, true
+}
+
+//surfaceHeight is Height for the mesh alone, false where it has no surface above or below x,z
+func (t *Terrain) surfaceHeight(x, z float64) (float64, mgl64.Vec3, bool) {
+	best := math.Inf(-1)
+	bestNormal := mgl64.Vec3{}
+	check := func(indices []int32) {
+		for _, i := range indices {
+			tri := &t.Triangles[i]
+			if math.Abs(tri.Normal[1]) < 1e-6 {
+				//Vertical faces have no height
+				continue
+			}
+			//Solve the plane equation for y
+			y := tri.A[1] - ((x-tri.A[0])*tri.Normal[0]+(z-tri.A[2])*tri.Normal[2])/tri.Normal[1]
+			if y > best && tri.contains(mgl64.Vec3{x, y, z}) {
+				best = y
+				bestNormal = tri.Normal
+			}
+		}
+	}
+	if len(t.Triangles) == 0 {
+		return 0, mgl64.Vec3{}, false
+	}
+	if x >= t.minX && z >= t.minZ {
+		cx, cz := t.cellOf(x, z)
+		check(t.cells[cx+cz*t.cellsX])
+	}
+	check(t.outsideCells)
+	if math.IsInf(best, -1) {
+		return 0, mgl64.Vec3{}, false
+	}
+	return best, bestNormal, true
+}
+
+//penetration is how far p is behind the triangle, if its projection onto the plane lands inside it
+func (tri *Triangle) penetration(p mgl64.Vec3) (float64, bool) {
+	depth := -p.Sub(tri.A).Dot(tri.Normal)
+	if depth <= 0 || depth > maxPenetration {
+		return 0, false
+	}
+	projected := p.Add(tri.Normal.Mul(depth))
+	return depth, tri.contains(projected)
+}
+
+//crossing finds where the path from from to to passes through the triangle from its front
+//Returns how far along the path that is, from 0 to 1, and how far behind the surface to ends up
+func (tri *Triangle) crossing(from, to mgl64.Vec3) (float64, float64, bool) {
+	before := from.Sub(tri.A).Dot(tri.Normal)
+	after := to.Sub(tri.A).Dot(tri.Normal)
+	if before < 0 || after >= 0 {
+		return 0, 0, false
+	}
+	frac := before / (before - after)
+	return frac, -after, tri.contains(from.Add(to.Sub(from).Mul(frac)))
+}
+
+//contains checks if a point on the triangle's plane is inside it using barycentric coordinates
+func (tri *Triangle) contains(p mgl64.Vec3) bool {
+	v0 := tri.C.Sub(tri.A)
+	v1 := tri.B.Sub(tri.A)
+	v2 := p.Sub(tri.A)
+	d00 := v0.Dot(v0)
+	d01 := v0.Dot(v1)
+	d02 := v0.Dot(v2)
+	d11 := v1.Dot(v1)
+	d12 := v1.Dot(v2)
+	denom := d00*d11 - d01*d01
+	if denom == 0 {
+		return false
+	}
+	u := (d11*d02 - d01*d12) / denom
+	v := (d00*d12 - d01*d02) / denom
+	const eps = 1e-9
+	return u >= -eps && v >= -eps && u+v <= 1+eps
+}
+
+//boundsXZ is the x-z bounding box of the triangle, grown by maxPenetration
+func (tri *Triangle) boundsXZ() ([2]float64, [2]float64) {
+	lo := [2]float64{
+		math.Min(tri.A[0], math.Min(tri.B[0], tri.C[0])) - maxPenetration,
+		math.Min(tri.A[2], math.Min(tri.B[2], tri.C[2])) - maxPenetration,
+	}
+	hi := [2]float64{
+		math.Max(tri.A[0], math.Max(tri.B[0], tri.C[0])) + maxPenetration,
+		math.Max(tri.A[2], math.Max(tri.B[2], tri.C[2])) + maxPenetration,
+	}
+	return lo, hi
+}
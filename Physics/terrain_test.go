@@ -0,0 +1,60 @@
+package plane_physics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//slopeTerrain is a 100 m square rising 0.4 m per m along x, 10 m up where it crosses x=0 so the y=0 plane is well below it
+func slopeTerrain() *Terrain {
+	height := func(x float64) float64 { return 10 + 0.4*x }
+	corner := func(x, z float64) mgl64.Vec3 { return mgl64.Vec3{x, height(x), z} }
+	a, b, c, d := corner(-50, -50), corner(50, -50), corner(50, 50), corner(-50, 50)
+	return NewTerrain([][3]mgl64.Vec3{{a, b, c}, {a, c, d}})
+}
+
+func TestTerrainSweptContact(t *testing.T) {
+	tr := slopeTerrain()
+	from, to := mgl64.Vec3{0, 11, 0}, mgl64.Vec3{0, 8, 0}
+	if _, _, ok := tr.Contact(to); ok {
+		t.Fatalf("%v is further behind the slope than maxPenetration, Contact shouldn't reach it", to)
+	}
+	depth, normal, ok := tr.SweptContact(from, to)
+	if !ok {
+		t.Fatal("no contact for a path through the slope")
+	}
+	want := mgl64.Vec3{-0.4, 1, 0}.Normalize()
+	if !normal.ApproxEqualThreshold(want, 1e-9) {
+		t.Errorf("normal %v, want %v", normal, want)
+	}
+	if wantDepth := 2 * want[1]; math.Abs(depth-wantDepth) > 1e-9 {
+		t.Errorf("depth %g, want %g", depth, wantDepth)
+	}
+	if _, _, ok := tr.SweptContact(mgl64.Vec3{0, 8, 0}, mgl64.Vec3{0, 7, 0}); ok {
+		t.Error("contact for a path that stays under the slope")
+	}
+}
+
+func TestTerrainFastDrop(t *testing.T) {
+	ps := InitPhysicsContext()
+	ps.Terrain = slopeTerrain()
+	//Slow enough steps that the box falls well over maxPenetration in the last one
+	ps.PhysicsRate = 30
+	m := ps.Model
+	m.Position = mgl64.Vec3{5, 80, 0}
+	m.Momentum = mgl64.Vec3{0, -20, 0}.Mul(m.Mass)
+	ps.AddBody(NewBody(Sphere{Radius: 0.3}, 1, mgl64.Vec3{-5, 80, 5}, mgl64.QuatIdent()))
+	ps.Bodies[1].Momentum = mgl64.Vec3{0, -20, 0}
+	dt := 1 / ps.PhysicsRate
+	for i := 0; i < 5*int(ps.PhysicsRate); i++ {
+		ps.Step(dt)
+	}
+	for i, b := range ps.Bodies {
+		ground, _, _ := ps.Terrain.Height(b.Position[0], b.Position[2])
+		if b.Position[1] < ground-0.1 {
+			t.Errorf("body %d fell through the slope, it is at %.2f m and the ground at %.2f m", i, b.Position[1], ground)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package plane_physics
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//WindField gives the velocity of the air in world space (m/s) at a point and simulated time
+//Heights are measured from the world's y=0, the field the pilot stands on
+type WindField interface {
+	WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3
+}
+
+//AirspeedFollower is implemented by wind fields whose time scales depend on how fast the aircraft moves through them
+//The sim tells them the airspeed after every step
+type AirspeedFollower interface {
+	SetAirspeed(v float64)
+}
+
+//WindFields adds the velocities of several fields
+type WindFields []WindField
+
+func (wf WindFields) WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3 {
+	sum := mgl64.Vec3{}
+	for _, f := range wf {
+		sum = sum.Add(f.WindAt(pos, t))
+	}
+	return sum
+}
+
+func (wf WindFields) SetAirspeed(v float64) {
+	for _, f := range wf {
+		if a, ok := f.(AirspeedFollower); ok {
+			a.SetAirspeed(v)
+		}
+	}
+}
+
+//SteadyWind is the same everywhere and never changes
+type SteadyWind struct {
+	Speed     float64 //m/s
+	Direction float64 //rad the wind blows towards, from +x turning towards +z
+}
+
+func (sw *SteadyWind) WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3 {
+	return sw.vector(sw.Speed)
+}
+
+func (sw *SteadyWind) vector(speed float64) mgl64.Vec3 {
+	return mgl64.Vec3{math.Cos(sw.Direction), 0, math.Sin(sw.Direction)}.Mul(speed)
+}
+
+//BoundaryLayer is a steady wind slowed near the ground following the logarithmic wind profile
+//Speed is the wind at RefHeight, a Roughness of 0 turns the shear off
+type BoundaryLayer struct {
+	SteadyWind
+	RefHeight float64 //m, usually 10
+	Roughness float64 //aerodynamic roughness length in m, 0.03 for short grass
+}
+
+func (bl *BoundaryLayer) WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3 {
+	if bl.Roughness <= 0 {
+		return bl.vector(bl.Speed)
+	}
+	h := pos[1]
+	if h <= bl.Roughness {
+		return mgl64.Vec3{}
+	}
+	scale := math.Log(h/bl.Roughness) / math.Log(bl.RefHeight/bl.Roughness)
+	return bl.vector(bl.Speed * scale)
+}
+
+//Gust is a discrete 1-cosine gust, rising from nothing to Peak and back over Duration
+type Gust struct {
+	Start    float64    //s of simulated time
+	Duration float64    //s
+	Peak     mgl64.Vec3 //m/s in world space
+}
+
+//Gusts is a set of discrete gusts, each the same everywhere
+type Gusts struct {
+	Gusts []Gust
+}
+
+//Add schedules a gust and forgets any that have already passed
+func (gs *Gusts) Add(gust Gust) {
+	kept := gs.Gusts[:0]
+	for _, g := range gs.Gusts {
+		if g.Start+g.Duration > gust.Start {
+			kept = append(kept, g)
+		}
+	}
+	gs.Gusts = append(kept, gust)
+}
+
+func (gs *Gusts) WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3 {
+	sum := mgl64.Vec3{}
+	for _, g := range gs.Gusts {
+		into := t - g.Start
+		if into < 0 || into > g.Duration {
+			continue
+		}
+		sum = sum.Add(g.Peak.Mul(0.5 * (1 - math.Cos(2*math.Pi*into/g.Duration))))
+	}
+	return sum
+}
+
+//Turbulence spectra
+const (
+	TurbulenceNone      = "none"
+	TurbulenceDryden    = "dryden"
+	TurbulenceVonKarman = "vonkarman"
+)
+
+var TurbulenceModels = []string{TurbulenceNone, TurbulenceDryden, TurbulenceVonKarman}
+
+//How often (Hz) new turbulence is generated, queries in between are interpolated
+const turbulenceSampleRate = 100
+
+//Turbulence is MIL-F-8785C low altitude continuous turbulence
+//Velocities are filtered white noise, uniform in space but with intensity and length scales set by the height
+//The longitudinal component lies along Direction, usually that of the mean wind
+type Turbulence struct {
+	Model     string  //one of TurbulenceModels
+	W20       float64 //m/s wind speed at 6 m (20 ft) which sets the intensity
+	Direction float64 //rad, as in SteadyWind
+	Seed      int64
+
+	airspeed float64
+	height   float64 //of the last query
+
+	built      string //model the filters were made for
+	rng        *rand.Rand
+	filters    [3]*shapingFilter //longitudinal, lateral, vertical
+	sampleTime float64
+	prev, cur  mgl64.Vec3 //filter outputs in turbulence axes, unscaled
+	prevSigma  mgl64.Vec3
+	curSigma   mgl64.Vec3
+}
+
+func (tb *Turbulence) SetAirspeed(v float64) {
+	tb.airspeed = v
+}
+
+//Reset restarts the noise from the seed
+func (tb *Turbulence) Reset() {
+	tb.built = ""
+}
+
+func (tb *Turbulence) WindAt(pos mgl64.Vec3, t float64) mgl64.Vec3 {
+	if tb.Model == TurbulenceNone || tb.Model == "" || tb.W20 <= 0 {
+		return mgl64.Vec3{}
+	}
+	tb.height = pos[1]
+	if tb.built != tb.Model || t < tb.sampleTime-1 {
+		tb.build(t)
+	}
+
+	sampleDT := 1.0 / turbulenceSampleRate
+	for t > tb.sampleTime {
+		tb.sample(sampleDT)
+	}
+
+	//Blend between the two samples around t
+	alpha := clamp(1-(tb.sampleTime-t)/sampleDT, 0, 1)
+	u, v, w := 0.0, 0.0, 0.0
+	for i, out := range []*float64{&u, &v, &w} {
+		a := tb.prev[i] * tb.prevSigma[i]
+		b := tb.cur[i] * tb.curSigma[i]
+		*out = a + (b-a)*alpha
+	}
+	along := mgl64.Vec3{math.Cos(tb.Direction), 0, math.Sin(tb.Direction)}
+	across := mgl64.Vec3{-along[2], 0, along[0]}
+	return along.Mul(u).Add(across.Mul(v)).Add(mgl64.Vec3{0, w, 0})
+}
+
+//build makes the shaping filters for the model and restarts the noise at time t
+//Starting at t rather than 0 keeps a late change from running the filters over the whole flight at once
+func (tb *Turbulence) build(t float64) {
+	tb.rng = rand.New(rand.NewSource(tb.Seed))
+	switch tb.Model {
+	case TurbulenceVonKarman:
+		//Rational approximations of the von Karman spectra
+		tb.filters[0] = newShapingFilter([]float64{1, 0.25}, []float64{1, 1.357, 0.1987})
+		lateral := func() *shapingFilter {
+			return newShapingFilter([]float64{1, 5.4956, 1.3592}, []float64{1, 5.9916, 7.9016, 1.2312})
+		}
+		tb.filters[1], tb.filters[2] = lateral(), lateral()
+	default:
+		tb.filters[0] = newShapingFilter([]float64{1}, []float64{1, 1})
+		lateral := func() *shapingFilter {
+			return newShapingFilter([]float64{1, math.Sqrt(3)}, []float64{1, 2, 1})
+		}
+		tb.filters[1], tb.filters[2] = lateral(), lateral()
+	}
+	tb.built = tb.Model
+	tb.sampleTime = t
+	tb.prev, tb.cur = mgl64.Vec3{}, mgl64.Vec3{}
+	tb.prevSigma, tb.curSigma = mgl64.Vec3{}, mgl64.Vec3{}
+}
+
+//sample advances the filters by one sample
+func (tb *Turbulence) sample(dt float64) {
+	sigma, lengths := tb.scales()
+	//The frozen turbulence is swept past at the airspeed, but never slower than walking pace
+	speed := math.Max(tb.airspeed, 1)
+	tb.prev, tb.prevSigma = tb.cur, tb.curSigma
+	for i, f := range tb.filters {
+		tb.cur[i] = f.step(tb.rng.NormFloat64()/math.Sqrt(dt), lengths[i]/speed, dt)
+	}
+	tb.curSigma = sigma
+	tb.sampleTime += dt
+}
+
+//scales gives the intensities and length scales of the three components at the last queried height
+func (tb *Turbulence) scales() (mgl64.Vec3, mgl64.Vec3) {
+	const ftToM = 0.3048
+	//The low altitude model is defined between 10 and 1000 ft
+	h := clamp(tb.height/ftToM, 10, 1000)
+	sigmaW := 0.1 * tb.W20
+	ratio := 1 / math.Pow(0.177+0.000823*h, 0.4)
+	lengthUV := h / math.Pow(0.177+0.000823*h, 1.2) * ftToM
+	return mgl64.Vec3{sigmaW * ratio, sigmaW * ratio, sigmaW},
+		mgl64.Vec3{lengthUV, lengthUV, h * ftToM}
+}
+
+//shapingFilter is a transfer function N(x)/D(x) of x = tau*s, scaled to unit output variance for unit white noise
+//It is simulated in controllable canonical form
+type shapingFilter struct {
+	num, den []float64 //coefficients from the constant term up
+	state    []float64
+	variance float64 //of the unscaled filter for tau = 1
+}
+
+func newShapingFilter(num, den []float64) *shapingFilter {
+	f := shapingFilter{num: num, den: den, state: make([]float64, len(den)-1)}
+	//(1/pi) * integral of |H(jx)|^2 over x from 0 to infinity, with x = tan(theta)
+	const n = 4000
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		theta := (float64(i) + 0.5) / n * math.Pi / 2
+		x := math.Tan(theta)
+		dx := (1 + x*x) * math.Pi / 2 / n
+		sum += f.gainSquared(x) * dx
+	}
+	f.variance = sum / math.Pi
+	return &f
+}
+
+func (f *shapingFilter) gainSquared(x float64) float64 {
+	poly := func(c []float64) complex128 {
+		v := complex(0, 0)
+		for i := len(c) - 1; i >= 0; i-- {
+			v = v*complex(0, x) + complex(c[i], 0)
+		}
+		return v
+	}
+	h := poly(f.num) / poly(f.den)
+	return real(h)*real(h) + imag(h)*imag(h)
+}
+
+//step advances the filter by dt with input w held constant, returning the new output
+func (f *shapingFilter) step(w, tau, dt float64) float64 {
+	n := len(f.state)
+	deriv := func(z []float64) []float64 {
+		d := make([]float64, n)
+		copy(d, z[1:])
+		last := w
+		for i := 0; i < n; i++ {
+			last -= f.den[i] * z[i]
+		}
+		d[n-1] = last / f.den[n]
+		for i := range d {
+			d[i] /= tau
+		}
+		return d
+	}
+	axpy := func(z, d []float64, k float64) []float64 {
+		out := make([]float64, n)
+		for i := range z {
+			out[i] = z[i] + k*d[i]
+		}
+		return out
+	}
+	//RK4, split so every sub step is a small fraction of tau
+	steps := int(math.Ceil(dt / (0.1 * tau)))
+	h := dt / float64(steps)
+	for s := 0; s < steps; s++ {
+		z := f.state
+		k1 := deriv(z)
+		k2 := deriv(axpy(z, k1, h/2))
+		k3 := deriv(axpy(z, k2, h/2))
+		k4 := deriv(axpy(z, k3, h))
+		for i := range z {
+			z[i] += h / 6 * (k1[i] + 2*k2[i] + 2*k3[i] + k4[i])
+		}
+	}
+
+	y := 0.0
+	for i, b := range f.num {
+		y += b * f.state[i]
+	}
+	//Output variance is variance/tau for unit noise
+	return y * math.Sqrt(tau/f.variance)
+}
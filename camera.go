@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/AllenDang/imgui-go"
+	graphics "github.com/cowsed/GoFly/Graphics"
+	plane_physics "github.com/cowsed/GoFly/Physics"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Camera modes, C cycles through them in this order
+const (
+	CameraGroundPilot = "ground-pilot" //standing on the field watching the airplane like an RC pilot
+	CameraChase       = "chase"        //following behind the airplane
+	CameraCockpit     = "cockpit"      //riding on board
+	CameraOrbit       = "orbit"        //circling a point that stays put
+)
+
+var CameraModes = []string{CameraGroundPilot, CameraChase, CameraCockpit, CameraOrbit}
+
+const (
+	autoZoomDistance = 25.0 //m, past this the ground pilot's view narrows so the airplane stays the same size
+	minAutoZoomFOV   = 8.0  //degrees
+	chaseDistance    = 5.0  //m behind the airplane
+	chaseHeight      = 1.2  //m above the airplane
+	chaseStiffness   = 4.0  //1/s, how quickly the chase camera catches up
+	minOrbitDistance = 0.5  //m
+)
+
+//Where the onboard camera sits in model space, about where a canopy would be
+var cockpitEye = mgl64.Vec3{0, 0.12, 0.25}
+
+//CameraController places the render camera every frame for the chosen mode
+type CameraController struct {
+	Mode     string
+	FOV      float64    //degrees, the widest the view gets
+	AutoZoom bool       //narrow the ground pilot's view as the airplane flies away
+	Pilot    mgl64.Vec3 //m, the ground pilot's eyes
+
+	//Orbit mode
+	Target   mgl64.Vec3 //point circled
+	Yaw      float64    //rad about the vertical of the camera from the target, from +z towards +x
+	Pitch    float64    //rad of the camera above the target
+	Distance float64    //m from the target
+
+	chase    mgl64.Vec3
+	chaseSet bool
+}
+
+func NewCameraController(mode string, fov float64, autoZoom bool, pilot mgl64.Vec3) *CameraController {
+	c := CameraController{FOV: fov, AutoZoom: autoZoom, Pilot: pilot, Distance: 10}
+	if !validCameraMode(mode) {
+		log.Printf("Unknown camera mode %q, using %s", mode, CameraGroundPilot)
+		mode = CameraGroundPilot
+	}
+	c.Mode = mode
+	return &c
+}
+
+func validCameraMode(mode string) bool {
+	for _, m := range CameraModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+//SetMode switches mode, starting the new one from where the camera is now so the view doesn't jump more than it has to
+func (c *CameraController) SetMode(mode string, cam *graphics.Camera, state plane_physics.BodyState) {
+	c.Mode = mode
+	c.chaseSet = false
+	if mode == CameraOrbit {
+		c.Target = state.Position
+		offset := mgl64.Vec3(V32toV64(cam.Position)).Sub(c.Target)
+		c.Distance = math.Max(offset.Len(), minOrbitDistance)
+		c.Yaw = math.Atan2(offset[0], offset[2])
+		c.Pitch = math.Asin(mgl64.Clamp(offset[1]/c.Distance, -1, 1))
+	}
+}
+
+//Update points the camera for a frame dt seconds after the last one
+//velocity is the airplane's, zero when it isn't known
+func (c *CameraController) Update(cam *graphics.Camera, state plane_physics.BodyState, velocity mgl64.Vec3, dt float64) {
+	pos := state.Position
+	eye, look, up := c.Pilot, pos, mgl64.Vec3{0, 1, 0}
+	fov := c.FOV
+
+	switch c.Mode {
+	case CameraGroundPilot:
+		if d := pos.Sub(eye).Len(); c.AutoZoom && d > autoZoomDistance {
+			//Keep the airplane's size on screen what it is at the zoom distance
+			half := math.Atan(math.Tan(mgl64.DegToRad(c.FOV)/2) * autoZoomDistance / d)
+			fov = math.Max(mgl64.RadToDeg(2*half), math.Min(minAutoZoomFOV, c.FOV))
+		}
+	case CameraChase:
+		//Behind the flight path, or the nose when barely moving
+		dir := mgl64.Vec3{velocity[0], 0, velocity[2]}
+		if dir.Len() < 1 {
+			nose := state.Orientation.Rotate(mgl64.Vec3{0, 0, 1})
+			dir = mgl64.Vec3{nose[0], 0, nose[2]}
+		}
+		if dir.Len() > 1e-6 {
+			dir = dir.Normalize()
+		}
+		want := pos.Sub(dir.Mul(chaseDistance)).Add(mgl64.Vec3{0, chaseHeight, 0})
+		if !c.chaseSet || dt <= 0 {
+			c.chase, c.chaseSet = want, true
+		} else {
+			c.chase = c.chase.Add(want.Sub(c.chase).Mul(1 - math.Exp(-chaseStiffness*dt)))
+		}
+		eye = c.chase
+	case CameraCockpit:
+		eye = pos.Add(state.Orientation.Rotate(cockpitEye))
+		look = eye.Add(state.Orientation.Rotate(mgl64.Vec3{0, 0, 1}))
+		up = state.Orientation.Rotate(mgl64.Vec3{0, 1, 0})
+	case CameraOrbit:
+		c.Pitch = mgl64.Clamp(c.Pitch, -math.Pi/2+0.01, math.Pi/2-0.01)
+		c.Distance = math.Max(c.Distance, minOrbitDistance)
+		forward, _, _ := c.axes()
+		look = c.Target
+		eye = c.Target.Sub(forward.Mul(c.Distance))
+	}
+	cam.Position = V64toV32(eye)
+	cam.Lookat = V64toV32(look)
+	cam.Up = V64toV32(up)
+	cam.FOV = float32(fov)
+}
+
+//CameraControls picks the camera mode and adjusts it
+func CameraControls(s *Sim) {
+	c := s.camera
+	if imgui.BeginCombo("Camera", c.Mode) {
+		for _, mode := range CameraModes {
+			if imgui.SelectableV(mode, mode == c.Mode, 0, imgui.Vec2{}) {
+				s.SetCameraMode(mode)
+			}
+		}
+		imgui.EndCombo()
+	}
+	SliderFloat64("FOV", &c.FOV, 5, 150)
+	if c.Mode == CameraGroundPilot {
+		imgui.Checkbox("Auto zoom", &c.AutoZoom)
+		imgui.Text(fmt.Sprintf("Zoomed to %.1f deg", s.gfxContext.Cam.FOV))
+	}
+	if c.Mode == CameraOrbit {
+		DragFloat364("Orbit target", (*[3]float64)(&c.Target), 0.05, -1000, 1000, "%.2f")
+		DragFloat64("Orbit distance", &c.Distance, 0.05, minOrbitDistance, 1000)
+	}
+}
+
+//SetCameraMode switches the camera and saves the choice
+func (s *Sim) SetCameraMode(mode string) {
+	s.setCameraMode(mode)
+	Settings.CameraMode = mode
+	Settings.CameraFOV = float32(s.camera.FOV)
+	Settings.CameraAutoZoom = s.camera.AutoZoom
+	err := SaveConfigFields(map[string]interface{}{
+		"CameraMode":     Settings.CameraMode,
+		"CameraFOV":      Settings.CameraFOV,
+		"CameraAutoZoom": Settings.CameraAutoZoom,
+	})
+	if err != nil {
+		log.Println("Saving camera mode:", err)
+	}
+}
+
+//setCameraMode switches the camera for now, the saved choice stays as it was
+func (s *Sim) setCameraMode(mode string) {
+	s.camera.SetMode(mode, &s.gfxContext.Cam, s.mod.drawnState)
+}
+
+//CycleCamera moves on to the next camera mode and saves it
+func (s *Sim) CycleCamera() {
+	c := s.camera
+	next := 0
+	for i, m := range CameraModes {
+		if m == c.Mode {
+			next = (i + 1) % len(CameraModes)
+		}
+	}
+	s.SetCameraMode(CameraModes[next])
+}
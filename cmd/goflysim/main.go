@@ -0,0 +1,96 @@
+//goflysim runs the flight model without a window, for regression runs on machines with no display
+//
+//	goflysim -scenario scenario.json -inputs inputs.json -out trajectory.csv
+//
+//The scenario sets the airplane, scenery, launch and weather, see Scenario for the fields.
+//The inputs are a JSON list of keyframes like [{"Time": 0, "Throttle": 1}, {"Time": 5, "Elevator": 0.2}].
+//The trajectory is written as CSV, or as JSON when the output file name ends in .json.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	scenarioPath := flag.String("scenario", "", "scenario JSON file, empty for the defaults")
+	inputsPath := flag.String("inputs", "", "control input timeline JSON file, empty for centered controls")
+	outPath := flag.String("out", "-", "trajectory file, .json for JSON and anything else for CSV, - for CSV on stdout")
+	aircraft := flag.String("aircraft", "", "airplane description, overrides the scenario's")
+	launch := flag.String("launch", "", "launch preset, overrides the scenario's")
+	duration := flag.Float64("duration", 0, "seconds to simulate, overrides the scenario's")
+	sampleRate := flag.Float64("rate", 50, "trajectory samples per simulated second")
+	flag.Parse()
+
+	sc, err := LoadScenario(*scenarioPath)
+	check(err)
+	if *aircraft != "" {
+		sc.Aircraft = *aircraft
+	}
+	if *launch != "" {
+		sc.Launch = *launch
+	}
+	if *duration > 0 {
+		sc.Duration = *duration
+	}
+	timeline, err := LoadTimeline(*inputsPath)
+	check(err)
+
+	samples, err := Run(sc, timeline, *sampleRate)
+	check(err)
+
+	var w io.Writer = os.Stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		check(err)
+		defer f.Close()
+		w = f
+	}
+	if strings.EqualFold(filepath.Ext(*outPath), ".json") {
+		err = WriteJSON(w, sc, samples)
+	} else {
+		err = WriteCSV(w, samples)
+	}
+	check(err)
+	log.Printf("Simulated %.1f s, %d samples", sc.Duration, len(samples))
+}
+
+//Run steps the scenario for its duration with the timeline's inputs, sampling the state sampleRate times a second
+func Run(sc Scenario, timeline *Timeline, sampleRate float64) ([]Sample, error) {
+	if sampleRate <= 0 || sampleRate > sc.PhysicsRate {
+		return nil, fmt.Errorf("sample rate %v must be above 0 and at most the physics rate %v", sampleRate, sc.PhysicsRate)
+	}
+	ps, err := sc.Build()
+	if err != nil {
+		return nil, err
+	}
+	dt := 1 / sc.PhysicsRate
+	steps := int(sc.Duration*sc.PhysicsRate + 0.5)
+	stepsPerSample := int(sc.PhysicsRate/sampleRate + 0.5)
+
+	samples := []Sample{}
+	for i := 0; i <= steps; i++ {
+		ps.Controls = timeline.At(ps.SumDT)
+		if i%stepsPerSample == 0 {
+			s := SampleOf(ps)
+			//Summing the steps drifts, the sample times should be exact for comparing runs
+			s.Time = float64(i) * dt
+			samples = append(samples, s)
+		}
+		if i < steps {
+			ps.Step(dt)
+		}
+	}
+	return samples, nil
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	plane_physics "github.com/cowsed/GoFly/Physics"
+)
+
+func testScenario(seed int64) Scenario {
+	sc := DefaultScenario
+	sc.Aircraft = "../../Assets/Planes/allegro.xml"
+	sc.Scenery = "../../Assets/Scenery/Scenery.ac"
+	sc.Duration = 3
+	sc.Seed = seed
+	sc.WindSpeed = 4
+	sc.TurbulenceModel = plane_physics.TurbulenceDryden
+	sc.TurbulenceW20 = 6
+	return sc
+}
+
+func TestRunRepeats(t *testing.T) {
+	throttle, elevator := 1.0, 0.3
+	timeline := &Timeline{Keyframes: []Keyframe{
+		{Time: 0, Throttle: &throttle},
+		{Time: 1, Elevator: &elevator},
+	}}
+	first, err := Run(testScenario(3), timeline, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Run(testScenario(3), timeline, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 151 {
+		t.Fatalf("got %d samples, want 151", len(first))
+	}
+	for i := range first {
+		if !reflect.DeepEqual(first[i], second[i]) {
+			t.Fatalf("runs differ at %g s:\n%+v\n%+v", first[i].Time, first[i], second[i])
+		}
+	}
+
+	//The seed has to be what makes them the same
+	other, err := Run(testScenario(4), timeline, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(first, other) {
+		t.Error("runs with different turbulence seeds are the same")
+	}
+}
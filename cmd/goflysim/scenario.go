@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/cowsed/GoFly/Graphics/ac"
+	plane_physics "github.com/cowsed/GoFly/Physics"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Scenario is everything about a run other than the pilot's inputs
+//Paths are relative to the working directory, like the GUI's config.json
+type Scenario struct {
+	Aircraft string  //CRRCSim airplane description
+	Config   string  //airplane config, empty for the first
+	Scenery  string  //AC3D scenery for the ground, empty for the flat plane y=0
+	Launch   string  //launch preset, empty for the airplane's first
+	Heading  float64 //degrees, launch direction turned from +z towards +x
+
+	Duration    float64 //s
+	PhysicsRate float64 //steps per simulated second
+	Integrator  string  //one of plane_physics.IntegratorNames
+	Seed        int64   //turbulence seed, runs with the same seed are identical
+
+	FieldElevation    float64 //m above sea level
+	TemperatureOffset float64 //K from the standard atmosphere
+	PressureOffset    float64 //Pa from the standard sea level pressure
+
+	WindSpeed       float64 //m/s at 10 m
+	WindDirection   float64 //degrees the wind blows towards
+	WindRoughness   float64 //m, 0 for no wind shear
+	TurbulenceModel string  //one of plane_physics.TurbulenceModels
+	TurbulenceW20   float64 //m/s
+}
+
+var DefaultScenario = Scenario{
+	Aircraft:        "Assets/Planes/allegro.xml",
+	Duration:        30,
+	PhysicsRate:     plane_physics.DefaultPhysicsRate,
+	Integrator:      plane_physics.IntegratorSemiImplicitEuler,
+	Seed:            1,
+	WindRoughness:   0.03,
+	TurbulenceModel: plane_physics.TurbulenceNone,
+}
+
+//LoadScenario reads a scenario file, anything missing keeps its default
+func LoadScenario(fname string) (Scenario, error) {
+	sc := DefaultScenario
+	if fname == "" {
+		return sc, nil
+	}
+	bytes, err := os.ReadFile(fname)
+	if err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal(bytes, &sc); err != nil {
+		return sc, fmt.Errorf("scenario %q: %v", fname, err)
+	}
+	return sc, nil
+}
+
+//Build sets up a physics simulation of the scenario, launched and ready to step
+func (sc *Scenario) Build() (*plane_physics.PhysicsSim, error) {
+	ps := plane_physics.InitPhysicsContext()
+	ps.PhysicsRate = sc.PhysicsRate
+	integrator, err := plane_physics.NewIntegrator(sc.Integrator)
+	if err != nil {
+		return nil, err
+	}
+	ps.Integrator = integrator
+	ps.Atmosphere = plane_physics.Atmosphere{
+		FieldElevation:    sc.FieldElevation,
+		TemperatureOffset: sc.TemperatureOffset,
+		PressureOffset:    sc.PressureOffset,
+	}
+
+	airplane, err := plane_physics.LoadAirplane(sc.Aircraft)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.LoadAirplane(airplane, sc.Config); err != nil {
+		return nil, err
+	}
+
+	if sc.Scenery != "" {
+		mod, err := ac.LoadACFile(sc.Scenery)
+		if err != nil {
+			return nil, fmt.Errorf("scenery: %v", err)
+		}
+		tris32 := mod.Triangles()
+		tris := make([][3]mgl64.Vec3, len(tris32))
+		for i := range tris32 {
+			for j := range tris32[i] {
+				v := tris32[i][j]
+				tris[i][j] = mgl64.Vec3{float64(v[0]), float64(v[1]), float64(v[2])}
+			}
+		}
+		ps.Terrain = plane_physics.NewTerrain(tris)
+	}
+
+	direction := sc.WindDirection * math.Pi / 180
+	wind := &plane_physics.BoundaryLayer{
+		SteadyWind: plane_physics.SteadyWind{Speed: sc.WindSpeed, Direction: direction},
+		RefHeight:  10,
+		Roughness:  sc.WindRoughness,
+	}
+	turbulence := &plane_physics.Turbulence{
+		Model:     sc.TurbulenceModel,
+		W20:       sc.TurbulenceW20,
+		Direction: direction,
+		Seed:      sc.Seed,
+	}
+	ps.Wind = plane_physics.WindFields{wind, turbulence}
+
+	ps.Launch(ps.LaunchPreset(sc.Launch), sc.Heading*math.Pi/180)
+	return ps, nil
+}
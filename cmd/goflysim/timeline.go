@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	plane_physics "github.com/cowsed/GoFly/Physics"
+)
+
+//Keyframe sets some of the control channels at a time
+//Channels left out are interpolated between the keyframes that do set them
+type Keyframe struct {
+	Time     float64 //s since the launch
+	Elevator *float64
+	Aileron  *float64
+	Rudder   *float64
+	Throttle *float64
+	Flaps    *float64
+}
+
+//Timeline is a scripted set of control inputs
+//Each channel moves linearly from one keyframe setting it to the next, is centered before its first and holds after its last
+type Timeline struct {
+	Keyframes []Keyframe
+}
+
+//LoadTimeline reads a JSON list of keyframes, an empty file name gives centered controls for the whole run
+func LoadTimeline(fname string) (*Timeline, error) {
+	tl := Timeline{}
+	if fname == "" {
+		return &tl, nil
+	}
+	bytes, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, &tl.Keyframes); err != nil {
+		return nil, fmt.Errorf("timeline %q: %v", fname, err)
+	}
+	sort.SliceStable(tl.Keyframes, func(i, j int) bool {
+		return tl.Keyframes[i].Time < tl.Keyframes[j].Time
+	})
+	return &tl, nil
+}
+
+//At is the controls at time t
+func (tl *Timeline) At(t float64) plane_physics.ControlState {
+	c := plane_physics.ControlState{
+		Elevator: tl.channel(t, func(k *Keyframe) *float64 { return k.Elevator }),
+		Aileron:  tl.channel(t, func(k *Keyframe) *float64 { return k.Aileron }),
+		Rudder:   tl.channel(t, func(k *Keyframe) *float64 { return k.Rudder }),
+		Throttle: tl.channel(t, func(k *Keyframe) *float64 { return k.Throttle }),
+		Flaps:    tl.channel(t, func(k *Keyframe) *float64 { return k.Flaps }),
+	}
+	c.Clamp()
+	return c
+}
+
+func (tl *Timeline) channel(t float64, get func(k *Keyframe) *float64) float64 {
+	prevTime, prev := 0.0, 0.0
+	seen := false
+	for i := range tl.Keyframes {
+		k := &tl.Keyframes[i]
+		v := get(k)
+		if v == nil {
+			continue
+		}
+		if k.Time < t {
+			prevTime, prev, seen = k.Time, *v, true
+			continue
+		}
+		//First keyframe setting the channel at or after t
+		if !seen {
+			if k.Time == t {
+				return *v
+			}
+			return 0
+		}
+		return prev + (*v-prev)*(t-prevTime)/(k.Time-prevTime)
+	}
+	return prev
+}
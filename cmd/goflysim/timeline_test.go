@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTimelineAt(t *testing.T) {
+	half, one, neg := 0.5, 1.0, -0.4
+	tl := Timeline{Keyframes: []Keyframe{
+		{Time: 1, Elevator: &half},
+		{Time: 2, Throttle: &one},
+		{Time: 3, Elevator: &neg},
+	}}
+	tests := []struct {
+		time               float64
+		elevator, throttle float64
+	}{
+		//Centered before a channel's first keyframe
+		{0, 0, 0},
+		{0.99, 0, 0},
+		{1, 0.5, 0},
+		//Interpolated between keyframes setting the channel, others don't interrupt
+		{1.5, 0.275, 0},
+		{2, 0.05, 1},
+		{2.5, -0.175, 1},
+		//Held after the last
+		{3, -0.4, 1},
+		{10, -0.4, 1},
+	}
+	for _, test := range tests {
+		c := tl.At(test.time)
+		if math.Abs(c.Elevator-test.elevator) > 1e-12 || math.Abs(c.Throttle-test.throttle) > 1e-12 {
+			t.Errorf("at %g s got elevator %g throttle %g, want %g and %g", test.time, c.Elevator, c.Throttle, test.elevator, test.throttle)
+		}
+		if c.Aileron != 0 || c.Rudder != 0 || c.Flaps != 0 {
+			t.Errorf("at %g s channels without keyframes aren't centered: %+v", test.time, c)
+		}
+	}
+}
+
+func TestTimelineClamps(t *testing.T) {
+	big := 3.0
+	tl := Timeline{Keyframes: []Keyframe{{Time: 0, Elevator: &big, Throttle: &big}}}
+	if c := tl.At(1); c.Elevator != 1 || c.Throttle != 1 {
+		t.Errorf("got %+v, want elevator and throttle clamped to 1", c)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+
+	plane_physics "github.com/cowsed/GoFly/Physics"
+)
+
+//Sample is the state of the airplane at one time of the trajectory
+//Angles are in degrees, everything else in SI units
+type Sample struct {
+	Time                      float64
+	X, Y, Z                   float64
+	VX, VY, VZ                float64
+	Roll, Pitch, Heading      float64
+	Airspeed, Alpha, Beta     float64
+	LoadFactor                float64
+	Energy                    float64 //J, kinetic plus potential
+	Elevator, Aileron, Rudder float64
+	Throttle, Flaps           float64
+}
+
+//SampleOf records the simulation's current state
+func SampleOf(ps *plane_physics.PhysicsSim) Sample {
+	m := ps.Model
+	v := m.Velocity()
+	roll, pitch, heading := m.Attitude()
+	c := ps.Controls
+	return Sample{
+		Time: ps.SumDT,
+		X:    m.Position[0], Y: m.Position[1], Z: m.Position[2],
+		VX: v[0], VY: v[1], VZ: v[2],
+		Roll: deg(roll), Pitch: deg(pitch), Heading: deg(heading),
+		Airspeed: ps.Aero.Airspeed, Alpha: deg(ps.Aero.Alpha), Beta: deg(ps.Aero.Beta),
+		LoadFactor: ps.LoadFactor(),
+		Energy:     ps.TotalEnergy(),
+		Elevator:   c.Elevator, Aileron: c.Aileron, Rudder: c.Rudder,
+		Throttle: c.Throttle, Flaps: c.Flaps,
+	}
+}
+
+func deg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+var csvHeader = []string{
+	"time", "x", "y", "z", "vx", "vy", "vz",
+	"roll", "pitch", "heading", "airspeed", "alpha", "beta",
+	"load_factor", "energy",
+	"elevator", "aileron", "rudder", "throttle", "flaps",
+}
+
+func (s Sample) values() []float64 {
+	return []float64{
+		s.Time, s.X, s.Y, s.Z, s.VX, s.VY, s.VZ,
+		s.Roll, s.Pitch, s.Heading, s.Airspeed, s.Alpha, s.Beta,
+		s.LoadFactor, s.Energy,
+		s.Elevator, s.Aileron, s.Rudder, s.Throttle, s.Flaps,
+	}
+}
+
+//WriteCSV writes the trajectory with a header row
+func WriteCSV(w io.Writer, samples []Sample) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	row := make([]string, len(csvHeader))
+	for _, s := range samples {
+		for i, v := range s.values() {
+			row[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//WriteJSON writes the scenario that was run and its trajectory
+func WriteJSON(w io.Writer, sc Scenario, samples []Sample) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Scenario   Scenario
+		Trajectory []Sample
+	}{sc, samples})
+}
@@ -5,20 +5,116 @@ import (
 	"io"
 	"log"
 	"os"
+
+	plane_physics "github.com/cowsed/GoFly/Physics"
 )
 
 type Config struct {
-	CameraFOV       float32
+	CameraFOV       float32    //degrees, the widest view, the ground pilot camera zooms in from it
+	CameraMode      string     //one of CameraModes, C cycles through them
+	CameraAutoZoom  bool       //narrow the ground pilot's view as the airplane flies away
+	PilotPosition   [3]float64 //m where the ground pilot's eyes are, the height is above the ground
 	ModelPath       string
 	EnvironmentPath string
 	SceneryPath     string
+	//CRRCSim airplane description, replaces ModelPath with its own model when set
+	AircraftPath   string
+	AircraftConfig string
+	LaunchPreset   string //name of the launch preset R uses, empty for the airplane's first
+
+	PhysicsRate float64 //fixed physics steps per simulated second
+	MaxCatchUp  float64 //most real time in seconds simulated in one frame
+	TimeScale   float64 //1 is real time
+	Integrator  string  //one of plane_physics.IntegratorNames
+
+	FieldElevation    float64 //m above sea level
+	TemperatureOffset float64 //K from the standard atmosphere
+	PressureOffset    float64 //Pa from the standard sea level pressure
+
+	Seed int64 //for everything random in a session, 0 picks one from the clock
+
+	WindSpeed       float64 //m/s at 10 m
+	WindDirection   float64 //degrees the wind blows towards
+	WindRoughness   float64 //m, ground roughness for the wind shear, 0 for none
+	TurbulenceModel string  //one of plane_physics.TurbulenceModels
+	TurbulenceW20   float64 //m/s, wind at 6 m that sets the turbulence intensity
+	GustSpeed       float64 //m/s peak of a gust
+	GustDuration    float64 //s
+
+	ThermalCount    int     //thermals kept alive around the field
+	ThermalStrength float64 //m/s mean core updraft
+	ThermalRadius   float64 //m mean core radius
+	ThermalLifetime float64 //s mean lifetime
+	ThermalCeiling  float64 //m height thermals reach
+	ThermalArea     float64 //m radius around the field thermals are born in
+	RidgeLift       bool    //lift from the wind blowing up the scenery's slopes
+	ShowThermals    bool    //draw where the thermals are over the render
+
+	Props []PropConfig //objects placed around the field
+
+	HUD HUDConfig
+}
+
+//HUDConfig turns the heads-up display and each of its elements on and off
+type HUDConfig struct {
+	Enabled     bool
+	PitchLadder bool
+	Bank        bool
+	Heading     bool
+	Airspeed    bool //m/s
+	Altitude    bool //m above the field origin
+	Vario       bool
+	Throttle    bool
+	Battery     bool
+}
+
+//PropConfig is a scenery object the airplane can hit
+type PropConfig struct {
+	Name      string
+	Shape     string     //one of plane_physics.ShapeNames
+	Size      [3]float64 //m, edge lengths of a box or the diameter of a sphere in the first
+	Position  [3]float64 //m, the height is of the center above the ground
+	Heading   float64    //degrees turned about the vertical
+	Mass      float64    //kg, 0 for props that never move
+	ModelPath string     //drawn stretched to Size, empty for a plain box or ball
 }
 
 var DefaultConfig Config = Config{
 	CameraFOV:       90,
+	CameraMode:      CameraGroundPilot,
+	CameraAutoZoom:  true,
+	PilotPosition:   [3]float64{0, 1.7, 0},
 	ModelPath:       "Assets/Planes/cube.ac",
 	EnvironmentPath: "Assets/Environments/skybox/",
-	SceneryPath: "Assets/Scenery/Scenery.ac",
+	SceneryPath:     "Assets/Scenery/Scenery.ac",
+	AircraftPath:    "Assets/Planes/allegro.xml",
+	AircraftConfig:  "",
+	LaunchPreset:    "",
+	PhysicsRate:     plane_physics.DefaultPhysicsRate,
+	MaxCatchUp:      plane_physics.DefaultMaxCatchUp,
+	TimeScale:       1,
+	Integrator:      plane_physics.IntegratorSemiImplicitEuler,
+	WindRoughness:   0.03,
+	TurbulenceModel: plane_physics.TurbulenceNone,
+	GustSpeed:       3,
+	GustDuration:    2,
+	ThermalCount:    6,
+	ThermalStrength: 2,
+	ThermalRadius:   40,
+	ThermalLifetime: 600,
+	ThermalCeiling:  800,
+	ThermalArea:     600,
+	RidgeLift:       true,
+	Props: []PropConfig{
+		{Name: "Pylon", Shape: plane_physics.ShapeBox, Size: [3]float64{0.4, 4, 0.4}, Position: [3]float64{-15, 2, 40}},
+		{Name: "Shed", Shape: plane_physics.ShapeBox, Size: [3]float64{4, 2.5, 3}, Position: [3]float64{12, 1.25, -8}, Heading: 30},
+		{Name: "Crate", Shape: plane_physics.ShapeBox, Size: [3]float64{0.6, 0.6, 0.6}, Position: [3]float64{3, 0.3, 6}, Mass: 5},
+		{Name: "Ball", Shape: plane_physics.ShapeSphere, Size: [3]float64{0.5, 0.5, 0.5}, Position: [3]float64{-3, 0.25, 8}, Mass: 0.4},
+	},
+	HUD: HUDConfig{
+		Enabled: true, PitchLadder: true, Bank: true, Heading: true,
+		Airspeed: true, Altitude: true, Vario: true, Throttle: true, Battery: true,
+	},
 }
 
 func LoadConfig() Config {
@@ -39,8 +135,8 @@ func LoadConfig() Config {
 			panic(err)
 		}
 	}
-	//Load File
-	newConf := Config{}
+	//Load File, anything missing keeps its default
+	newConf := DefaultConfig
 	bytes, err := io.ReadAll(f)
 	check(err)
 	err = json.Unmarshal(bytes, &newConf)
@@ -48,6 +144,33 @@ func LoadConfig() Config {
 	log.Println("Loaded config.json")
 	return newConf
 }
+
+//SaveConfigFields updates only the named fields of config.json, everything else in the file is left as it is
+func SaveConfigFields(fields map[string]interface{}) error {
+	conf := map[string]json.RawMessage{}
+	bytes, err := os.ReadFile("config.json")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(bytes, &conf); err != nil {
+			return err
+		}
+	}
+	for name, v := range fields {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		conf[name] = raw
+	}
+	bytes, err = json.MarshalIndent(conf, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile("config.json", append(bytes, '\n'), 0644)
+}
+
 func check(err error) {
 	if err != nil {
 		panic(err)
@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	g "github.com/AllenDang/giu"
 	"github.com/AllenDang/imgui-go"
+	plane_physics "github.com/cowsed/GoFly/Physics"
 	"github.com/go-gl/mathgl/mgl64"
 )
 
@@ -19,14 +21,16 @@ func MakeUI() {
 		g.Labelf("FPS: %.2f", (1000 / dt)),
 		//g.Labelf("Contacting: %v", Simulation.physContext.Model.Contacting),
 		g.Checkbox("Paused", &Paused),
-		g.Checkbox("Follow", &followModel),
 
 		//g.InputFloat(&Simulation.gfxContext.Scene.Scale).Label("Scale"),
 		g.Labelf("sumDT: %v", Simulation.physContext.SumDT),
+		g.Labelf("Physics rate: %.0f Hz", Simulation.physContext.PhysicsRate),
+		g.Labelf("Airspeed: %.2f m/s", Simulation.physContext.Aero.Airspeed),
+		g.Labelf("Alpha: %.2f deg  Beta: %.2f deg", mgl64.RadToDeg(Simulation.physContext.Aero.Alpha), mgl64.RadToDeg(Simulation.physContext.Aero.Beta)),
+		g.Labelf("Wheels touching: %d/%d", Simulation.physContext.Model.WheelsInContact(), len(Simulation.physContext.Model.Wheels)),
 		g.Custom(func() {
 
-			DragFloat3("Camera Position", (*[3]float32)(&Simulation.gfxContext.Cam.Position), 0.01, -1000, 1000, "%f")
-			DragFloat3("Lookat Position", (*[3]float32)(&Simulation.gfxContext.Cam.Lookat), 0.01, -1000, 1000, "%f")
+			CameraControls(Simulation)
 			DragFloat364("Object Position", (*[3]float64)(&Simulation.physContext.Model.Position), 0.01, -1000, 1000, "%f")
 			DragFloat364("Object Momentum", (*[3]float64)(&Simulation.physContext.Model.Momentum), 0.01, -1000, 1000, "%f")
 			imgui.Separator()
@@ -34,10 +38,30 @@ func MakeUI() {
 			DragFloat364("Angular momentum", (*[3]float64)(&Simulation.physContext.Model.AngularMomentum), 0.01, -1000, 1000, "%f")
 			DragQuat64("Orientation", &Simulation.mod.physObj.Orientation, 0.01, -1, 1, true, "%f")
 
+			imgui.Separator()
+			controls := &Simulation.physContext.Controls
+			SliderFloat64("Elevator", &controls.Elevator, -1, 1)
+			SliderFloat64("Aileron", &controls.Aileron, -1, 1)
+			SliderFloat64("Rudder", &controls.Rudder, -1, 1)
+			SliderFloat64("Throttle", &controls.Throttle, 0, 1)
+			SliderFloat64("Flaps", &controls.Flaps, 0, 1)
+			PowerInfo(Simulation.physContext.Power)
+			imgui.Separator()
+
+			LaunchCombo(Simulation)
 			if imgui.Button("reset physics") {
-				Simulation.physContext.ResetPhysics()
+				Simulation.Relaunch()
 			}
-			imgui.DragFloatV("FOV", &Simulation.gfxContext.Cam.FOV, 0.1, .5, 179, "%f", 0)
+			SliderFloat64("Time scale", &Simulation.physContext.TimeScale, 0.05, 4)
+			imgui.BeginDisabled(Simulation.WorldLocked())
+			IntegratorCombo(Simulation.physContext)
+			AtmosphereControls(Simulation.physContext)
+			imgui.EndDisabled()
+			WindControls(Simulation)
+			LiftControls(Simulation)
+			HUDControls()
+			RecordingControls(Simulation)
+			TelemetryPanel(Simulation)
 		}),
 	}
 
@@ -64,6 +88,11 @@ func MakeUI() {
 			imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1},
 			imgui.Vec4{X: 0, Y: 0, Z: 0, W: 0},
 		)
+		ViewportInput(Simulation)
+		if Settings.ShowThermals {
+			DrawThermalOverlay(Simulation)
+		}
+		DrawHUD(Simulation)
 		imgui.ImageV(imgui.TextureID(Simulation.gfxContext.PPTexture),
 			size,
 			imgui.Vec2{X: 0, Y: 1},
@@ -86,12 +115,145 @@ func MakeUI() {
 
 }
 
+//IntegratorCombo lets the integrator be swapped while the simulation runs
+func IntegratorCombo(ps *plane_physics.PhysicsSim) {
+	if !imgui.BeginCombo("Integrator", ps.Integrator.Name()) {
+		return
+	}
+	for _, name := range plane_physics.IntegratorNames {
+		if imgui.SelectableV(name, name == ps.Integrator.Name(), 0, imgui.Vec2{}) {
+			integrator, err := plane_physics.NewIntegrator(name)
+			check(err)
+			ps.Integrator = integrator
+			Settings.Integrator = name
+		}
+	}
+	imgui.EndCombo()
+}
+
+//LaunchCombo picks the launch preset used by resets
+func LaunchCombo(s *Sim) {
+	current := s.physContext.LaunchPreset(Settings.LaunchPreset).Name
+	if !imgui.BeginCombo("Launch", current) {
+		return
+	}
+	for _, lp := range s.physContext.LaunchPresets() {
+		if imgui.SelectableV(lp.Name, lp.Name == current, 0, imgui.Vec2{}) {
+			Settings.LaunchPreset = lp.Name
+			s.Relaunch()
+		}
+	}
+	imgui.EndCombo()
+}
+
+//PowerInfo shows the state of every battery and what it drives
+func PowerInfo(pt *plane_physics.PowerTrain) {
+	if pt == nil {
+		return
+	}
+	for i, b := range pt.Batteries {
+		left := b.Capacity - b.Used
+		imgui.Text(fmt.Sprintf("Battery %d: %.0f mAh left (%.0f%%)", i+1, left/3.6, b.StateOfCharge()*100))
+		status := ""
+		if b.CutOff {
+			status = "  CUT OFF"
+		}
+		imgui.Text(fmt.Sprintf("  %.2f V  %.1f A  %.0f RPM%s", b.Voltage, b.Current, b.RPM(), status))
+	}
+}
+
+//AtmosphereControls shows the air at the aircraft and lets the day's conditions be changed
+func AtmosphereControls(ps *plane_physics.PhysicsSim) {
+	imgui.Separator()
+	air := ps.Air
+	imgui.Text(fmt.Sprintf("Altitude: %.1f m MSL", air.Altitude))
+	imgui.Text(fmt.Sprintf("Air: %.1f C  %.0f Pa  %.4f kg/m^3", air.Temperature-273.15, air.Pressure, air.Density))
+	imgui.Text(fmt.Sprintf("Speed of sound: %.1f m/s", air.SpeedOfSound))
+	DragFloat64("Field elevation (m)", &ps.Atmosphere.FieldElevation, 1, 0, 5000)
+	DragFloat64("Temperature offset (K)", &ps.Atmosphere.TemperatureOffset, 0.1, -40, 40)
+	DragFloat64("Pressure offset (Pa)", &ps.Atmosphere.PressureOffset, 10, -5000, 5000)
+}
+
+//WindControls adjusts the wind while flying
+func WindControls(s *Sim) {
+	imgui.Separator()
+	wind := s.physContext.LocalWind
+	imgui.Text(fmt.Sprintf("Wind at aircraft: %.1f m/s (vertical %.1f)", wind.Len(), wind[1]))
+
+	imgui.BeginDisabled(s.WorldLocked())
+	SliderFloat64("Wind speed (m/s)", &s.wind.Speed, 0, 20)
+	degrees := s.wind.Direction * 180 / math.Pi
+	if SliderFloat64("Wind direction (deg)", &degrees, 0, 360) {
+		s.wind.Direction = degrees * math.Pi / 180
+		s.turbulence.Direction = s.wind.Direction
+	}
+	shear := s.wind.Roughness > 0
+	if imgui.Checkbox("Wind shear near ground", &shear) {
+		s.wind.Roughness = 0
+		if shear {
+			s.wind.Roughness = DefaultConfig.WindRoughness
+		}
+	}
+
+	if imgui.BeginCombo("Turbulence", s.turbulence.Model) {
+		for _, name := range plane_physics.TurbulenceModels {
+			if imgui.SelectableV(name, name == s.turbulence.Model, 0, imgui.Vec2{}) {
+				s.turbulence.Model = name
+			}
+		}
+		imgui.EndCombo()
+	}
+	SliderFloat64("Turbulence W20 (m/s)", &s.turbulence.W20, 0, 25)
+	imgui.EndDisabled()
+
+	SliderFloat64("Gust speed (m/s)", &Settings.GustSpeed, 0, 15)
+	SliderFloat64("Gust duration (s)", &Settings.GustDuration, 0.2, 10)
+	if imgui.Button("Gust") {
+		s.AddGust()
+	}
+}
+
+//LiftControls adjusts the thermals and slope lift
+func LiftControls(s *Sim) {
+	imgui.Separator()
+	imgui.BeginDisabled(s.WorldLocked())
+	count := int32(s.thermals.Count)
+	if imgui.SliderInt("Thermals", &count, 0, 30) {
+		s.thermals.Count = int(count)
+	}
+	SliderFloat64("Thermal strength (m/s)", &s.thermals.Strength, 0, 6)
+	SliderFloat64("Thermal radius (m)", &s.thermals.Radius, 10, 150)
+	if imgui.Checkbox("Ridge lift", &Settings.RidgeLift) {
+		s.UpdateWindFields()
+	}
+	imgui.EndDisabled()
+	imgui.Checkbox("Show thermals", &Settings.ShowThermals)
+}
+
 func MakeLoadingUI() {
 	g.SingleWindow().Layout(
 		g.Label("Please Wait... Loading"),
 	)
 }
 
+func DragFloat64(label string, v64 *float64, speed, min, max float32) bool {
+	v := float32(*v64)
+	changed := imgui.DragFloatV(label, &v, speed, min, max, "%.2f", 0)
+	if changed {
+		*v64 = float64(v)
+	}
+	return changed
+}
+
+func SliderFloat64(label string, v64 *float64, min, max float32) bool {
+	v := float32(*v64)
+	changed := imgui.SliderFloatV(label, &v, min, max, "%.2f", 1)
+	if changed {
+		*v64 = float64(v)
+	}
+	return changed
+}
+
 func DragFloat3(label string, vec *[3]float32, speed float32, min, max float32, format string) bool {
 	value_changed := false
 	size := imgui.CalcItemWidth() / float32(len(vec)+1)
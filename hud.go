@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/AllenDang/imgui-go"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//HUD colors, bright on a dark backing so they read against sky and ground
+var (
+	hudColor   = imgui.Vec4{X: 0.2, Y: 1, Z: 0.3, W: 1}
+	hudShadow  = imgui.Vec4{X: 0, Y: 0, Z: 0, W: 0.8}
+	hudBacking = imgui.Vec4{X: 0, Y: 0, Z: 0, W: 0.35}
+	hudWarning = imgui.Vec4{X: 1, Y: 0.3, Z: 0.2, W: 1}
+)
+
+//Degrees of pitch the ladder shows over the height of the view
+const hudLadderSpan = 60.0
+
+//hud draws into a rectangle of the window, sizes are scaled to the rectangle's height
+type hud struct {
+	draw     imgui.DrawList
+	min, max imgui.Vec2
+	center   imgui.Vec2
+	scale    float32 //1 for an 800 pixel high view
+}
+
+func (h *hud) px(v float32) float32 {
+	return v * h.scale
+}
+
+//line draws a line with a dark outline
+func (h *hud) line(a, b imgui.Vec2, color imgui.Vec4) {
+	h.draw.AddLine(a, b, hudShadow, h.px(4))
+	h.draw.AddLine(a, b, color, h.px(2))
+}
+
+//text draws text with a dark shadow, anchor is 0 for left aligned, 0.5 centered and 1 right aligned
+func (h *hud) text(pos imgui.Vec2, anchor float32, color imgui.Vec4, text string) {
+	size := imgui.CalcTextSize(text, false, 0)
+	pos = imgui.Vec2{X: pos.X - size.X*anchor, Y: pos.Y - size.Y/2}
+	h.draw.AddText(imgui.Vec2{X: pos.X + 1, Y: pos.Y + 1}, hudShadow, text)
+	h.draw.AddText(pos, color, text)
+}
+
+//rotate turns p about the center of the view by angle (rad), clockwise on screen
+func (h *hud) rotate(p imgui.Vec2, angle float64) imgui.Vec2 {
+	s, c := float32(math.Sin(angle)), float32(math.Cos(angle))
+	dx, dy := p.X-h.center.X, p.Y-h.center.Y
+	return imgui.Vec2{X: h.center.X + dx*c - dy*s, Y: h.center.Y + dx*s + dy*c}
+}
+
+//DrawHUD draws the heads-up display over the last drawn image
+func DrawHUD(s *Sim) {
+	conf := Settings.HUD
+	if !conf.Enabled {
+		return
+	}
+	min, max := imgui.GetItemRectMin(), imgui.GetItemRectMax()
+	h := hud{
+		draw:   imgui.GetWindowDrawList(),
+		min:    min,
+		max:    max,
+		center: imgui.Vec2{X: (min.X + max.X) / 2, Y: (min.Y + max.Y) / 2},
+		scale:  (max.Y - min.Y) / 800,
+	}
+	imgui.PushClipRect(min, max, true)
+	defer imgui.PopClipRect()
+
+	ps := s.physContext
+	roll, pitch, heading := ps.Model.Attitude()
+	if conf.PitchLadder {
+		h.pitchLadder(roll, pitch)
+	}
+	if conf.Bank {
+		h.bank(roll)
+	}
+	if conf.Heading {
+		h.headingTape(heading)
+	}
+	if conf.Airspeed {
+		h.tape(min.X+h.px(70), ps.Aero.Airspeed, 1, 5, "%.0f", "m/s", false)
+	}
+	if conf.Altitude {
+		h.tape(max.X-h.px(70), ps.Model.Position[1], 5, 20, "%.0f", "m", true)
+	}
+	if conf.Vario {
+		h.vario(ps.Model.Velocity()[1])
+	}
+	if conf.Throttle {
+		h.throttle(ps.Controls.Throttle)
+	}
+	if conf.Battery && ps.Power != nil && len(ps.Power.Batteries) > 0 {
+		b := ps.Power.Batteries[0]
+		h.battery(b.StateOfCharge(), b.Voltage, b.CutOff)
+	}
+}
+
+//pitchLadder draws a line every 10 degrees of pitch turned with the bank, and the airplane symbol in the middle
+func (h *hud) pitchLadder(roll, pitch float64) {
+	perDegree := (h.max.Y - h.min.Y) / hudLadderSpan
+	pitchDeg := pitch * 180 / math.Pi
+	for deg := -90; deg <= 90; deg += 10 {
+		offset := float32(pitchDeg-float64(deg)) * perDegree
+		if math.Abs(float64(offset)) > float64(h.max.Y-h.min.Y)/2 {
+			continue
+		}
+		half := h.px(80)
+		gap := h.px(30)
+		if deg == 0 {
+			half = h.px(220)
+		}
+		y := h.center.Y + offset
+		left := [2]imgui.Vec2{{X: h.center.X - half, Y: y}, {X: h.center.X - gap, Y: y}}
+		right := [2]imgui.Vec2{{X: h.center.X + gap, Y: y}, {X: h.center.X + half, Y: y}}
+		for _, seg := range [][2]imgui.Vec2{left, right} {
+			a, b := h.rotate(seg[0], -roll), h.rotate(seg[1], -roll)
+			if deg < 0 {
+				//Dashed below the horizon
+				mid := imgui.Vec2{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+				h.line(a, imgui.Vec2{X: (a.X + mid.X) / 2, Y: (a.Y + mid.Y) / 2}, hudColor)
+				h.line(mid, imgui.Vec2{X: (mid.X + b.X) / 2, Y: (mid.Y + b.Y) / 2}, hudColor)
+			} else {
+				h.line(a, b, hudColor)
+			}
+		}
+		if deg != 0 {
+			label := fmt.Sprintf("%d", deg)
+			h.text(h.rotate(imgui.Vec2{X: h.center.X - half - h.px(8), Y: y}, -roll), 1, hudColor, label)
+			h.text(h.rotate(imgui.Vec2{X: h.center.X + half + h.px(8), Y: y}, -roll), 0, hudColor, label)
+		}
+	}
+
+	//Fixed airplane symbol
+	c := h.center
+	w := h.px(40)
+	h.line(imgui.Vec2{X: c.X - 2*w, Y: c.Y}, imgui.Vec2{X: c.X - w, Y: c.Y}, hudColor)
+	h.line(imgui.Vec2{X: c.X - w, Y: c.Y}, imgui.Vec2{X: c.X - w/2, Y: c.Y + w/2}, hudColor)
+	h.line(imgui.Vec2{X: c.X - w/2, Y: c.Y + w/2}, imgui.Vec2{X: c.X, Y: c.Y}, hudColor)
+	h.line(imgui.Vec2{X: c.X, Y: c.Y}, imgui.Vec2{X: c.X + w/2, Y: c.Y + w/2}, hudColor)
+	h.line(imgui.Vec2{X: c.X + w/2, Y: c.Y + w/2}, imgui.Vec2{X: c.X + w, Y: c.Y}, hudColor)
+	h.line(imgui.Vec2{X: c.X + w, Y: c.Y}, imgui.Vec2{X: c.X + 2*w, Y: c.Y}, hudColor)
+}
+
+//bank draws a scale arc over the middle of the view with a pointer at the bank angle
+func (h *hud) bank(roll float64) {
+	radius := h.px(260)
+	top := imgui.Vec2{X: h.center.X, Y: h.center.Y - radius}
+	for _, deg := range []float64{-60, -45, -30, -20, -10, 0, 10, 20, 30, 45, 60} {
+		length := h.px(10)
+		if math.Mod(deg, 30) == 0 {
+			length = h.px(20)
+		}
+		angle := deg * math.Pi / 180
+		outer := h.rotate(imgui.Vec2{X: top.X, Y: top.Y - length}, angle)
+		h.line(h.rotate(top, angle), outer, hudColor)
+	}
+	color := hudColor
+	if math.Abs(roll) > 60*math.Pi/180 {
+		color = hudWarning
+	}
+	tip := h.rotate(imgui.Vec2{X: top.X, Y: top.Y + h.px(2)}, -roll)
+	left := h.rotate(imgui.Vec2{X: top.X - h.px(10), Y: top.Y + h.px(18)}, -roll)
+	right := h.rotate(imgui.Vec2{X: top.X + h.px(10), Y: top.Y + h.px(18)}, -roll)
+	h.draw.AddTriangleFilled(tip, left, right, color)
+	h.text(imgui.Vec2{X: h.center.X, Y: top.Y - h.px(38)}, 0.5, color, fmt.Sprintf("%.0f", roll*180/math.Pi))
+}
+
+//headingTape draws a compass strip along the top of the view
+func (h *hud) headingTape(heading float64) {
+	deg := math.Mod(heading*180/math.Pi+360, 360)
+	width := h.px(400)
+	y := h.min.Y + h.px(40)
+	perDegree := width / 60
+	h.draw.AddRectFilled(imgui.Vec2{X: h.center.X - width/2, Y: y - h.px(22)}, imgui.Vec2{X: h.center.X + width/2, Y: y + h.px(22)}, hudBacking, 4, 0)
+	for tick := math.Ceil((deg-30)/5) * 5; tick <= deg+30; tick += 5 {
+		x := h.center.X + float32(tick-deg)*perDegree
+		length := h.px(6)
+		if math.Mod(tick, 10) == 0 {
+			length = h.px(12)
+			label := fmt.Sprintf("%02.0f", math.Mod(tick+360, 360)/10)
+			switch math.Mod(tick+360, 360) {
+			case 0:
+				label = "N"
+			case 90:
+				label = "E"
+			case 180:
+				label = "S"
+			case 270:
+				label = "W"
+			}
+			h.text(imgui.Vec2{X: x, Y: y - h.px(10)}, 0.5, hudColor, label)
+		}
+		h.line(imgui.Vec2{X: x, Y: y + h.px(18) - length}, imgui.Vec2{X: x, Y: y + h.px(18)}, hudColor)
+	}
+	h.draw.AddTriangleFilled(imgui.Vec2{X: h.center.X, Y: y + h.px(22)}, imgui.Vec2{X: h.center.X - h.px(8), Y: y + h.px(34)}, imgui.Vec2{X: h.center.X + h.px(8), Y: y + h.px(34)}, hudColor)
+	h.text(imgui.Vec2{X: h.center.X, Y: y + h.px(46)}, 0.5, hudColor, fmt.Sprintf("%03.0f", deg))
+}
+
+//tape draws a vertical scale centered on value, ticks every minor and labels every major unit
+//Ticks face into the view, right is for a tape on the right side
+func (h *hud) tape(x float32, value, minor, major float64, format, unit string, right bool) {
+	height := h.px(360)
+	width := h.px(70)
+	perUnit := height / float32(major*4)
+	side := float32(1)
+	if right {
+		side = -1
+	}
+	inner := x + side*width/2
+	h.draw.AddRectFilled(imgui.Vec2{X: x - width/2, Y: h.center.Y - height/2}, imgui.Vec2{X: x + width/2, Y: h.center.Y + height/2}, hudBacking, 4, 0)
+	span := 2 * major
+	for tick := math.Ceil((value-span)/minor) * minor; tick <= value+span; tick += minor {
+		y := h.center.Y - float32(tick-value)*perUnit
+		length := h.px(8)
+		if math.Mod(math.Abs(tick)+minor/2, major) < minor {
+			length = h.px(16)
+			h.text(imgui.Vec2{X: inner - side*h.px(22), Y: y}, 0.5, hudColor, fmt.Sprintf(format, tick))
+		}
+		h.line(imgui.Vec2{X: inner, Y: y}, imgui.Vec2{X: inner - side*length, Y: y}, hudColor)
+	}
+
+	//Current value box
+	boxHalf := imgui.Vec2{X: width/2 + h.px(6), Y: h.px(14)}
+	h.draw.AddRectFilled(imgui.Vec2{X: x - boxHalf.X, Y: h.center.Y - boxHalf.Y}, imgui.Vec2{X: x + boxHalf.X, Y: h.center.Y + boxHalf.Y}, hudShadow, 3, 0)
+	h.draw.AddRect(imgui.Vec2{X: x - boxHalf.X, Y: h.center.Y - boxHalf.Y}, imgui.Vec2{X: x + boxHalf.X, Y: h.center.Y + boxHalf.Y}, hudColor, 3, 0, h.px(2))
+	h.text(imgui.Vec2{X: x, Y: h.center.Y}, 0.5, hudColor, fmt.Sprintf("%.1f", value))
+	h.text(imgui.Vec2{X: x, Y: h.center.Y + height/2 + h.px(14)}, 0.5, hudColor, unit)
+}
+
+//vario draws a bar beside the altitude tape filling up or down with the climb rate
+func (h *hud) vario(climb float64) {
+	const fullScale = 5.0 //m/s
+	x := h.max.X - h.px(125)
+	half := h.px(150)
+	h.draw.AddRectFilled(imgui.Vec2{X: x - h.px(6), Y: h.center.Y - half}, imgui.Vec2{X: x + h.px(6), Y: h.center.Y + half}, hudBacking, 2, 0)
+	for _, v := range []float64{-5, -2.5, 0, 2.5, 5} {
+		y := h.center.Y - float32(v/fullScale)*half
+		h.line(imgui.Vec2{X: x - h.px(10), Y: y}, imgui.Vec2{X: x - h.px(6), Y: y}, hudColor)
+	}
+	bar := float32(mgl64.Clamp(climb/fullScale, -1, 1)) * half
+	color := hudColor
+	if climb < 0 {
+		color = hudWarning
+	}
+	h.draw.AddRectFilled(imgui.Vec2{X: x - h.px(4), Y: h.center.Y - maxF32(bar, 0)}, imgui.Vec2{X: x + h.px(4), Y: h.center.Y - minF32(bar, 0)}, color, 0, 0)
+	h.text(imgui.Vec2{X: x, Y: h.center.Y - half - h.px(14)}, 0.5, color, fmt.Sprintf("%+.1f", climb))
+}
+
+//throttle draws a vertical gauge in the bottom left corner
+func (h *hud) throttle(throttle float64) {
+	x := h.min.X + h.px(30)
+	bottom := h.max.Y - h.px(40)
+	height := h.px(120)
+	h.draw.AddRectFilled(imgui.Vec2{X: x - h.px(8), Y: bottom - height}, imgui.Vec2{X: x + h.px(8), Y: bottom}, hudBacking, 2, 0)
+	h.draw.AddRectFilled(imgui.Vec2{X: x - h.px(6), Y: bottom - height*float32(throttle)}, imgui.Vec2{X: x + h.px(6), Y: bottom}, hudColor, 0, 0)
+	h.text(imgui.Vec2{X: x, Y: bottom + h.px(16)}, 0.5, hudColor, fmt.Sprintf("THR %.0f%%", throttle*100))
+}
+
+//battery draws the charge left and voltage next to the throttle
+func (h *hud) battery(charge, voltage float64, cutOff bool) {
+	x := h.min.X + h.px(80)
+	bottom := h.max.Y - h.px(40)
+	height := h.px(120)
+	color := hudColor
+	if charge < 0.2 || cutOff {
+		color = hudWarning
+	}
+	h.draw.AddRectFilled(imgui.Vec2{X: x - h.px(12), Y: bottom - height}, imgui.Vec2{X: x + h.px(12), Y: bottom}, hudBacking, 2, 0)
+	h.draw.AddRect(imgui.Vec2{X: x - h.px(12), Y: bottom - height}, imgui.Vec2{X: x + h.px(12), Y: bottom}, color, 2, 0, h.px(2))
+	h.draw.AddRectFilled(imgui.Vec2{X: x - h.px(5), Y: bottom - height - h.px(6)}, imgui.Vec2{X: x + h.px(5), Y: bottom - height}, color, 0, 0)
+	h.draw.AddRectFilled(imgui.Vec2{X: x - h.px(9), Y: bottom - height*float32(mgl64.Clamp(charge, 0, 1))}, imgui.Vec2{X: x + h.px(9), Y: bottom - h.px(3)}, color, 0, 0)
+	label := fmt.Sprintf("%.0f%% %.1fV", charge*100, voltage)
+	if cutOff {
+		label = "CUT OFF"
+	}
+	h.text(imgui.Vec2{X: x + h.px(18), Y: bottom + h.px(16)}, 0.5, color, label)
+}
+
+//HUDControls toggles the HUD elements
+func HUDControls() {
+	imgui.Separator()
+	conf := &Settings.HUD
+	imgui.Checkbox("HUD", &conf.Enabled)
+	if !conf.Enabled {
+		return
+	}
+	toggles := []struct {
+		name string
+		v    *bool
+	}{
+		{"Pitch ladder", &conf.PitchLadder}, {"Bank", &conf.Bank},
+		{"Heading", &conf.Heading}, {"Airspeed", &conf.Airspeed},
+		{"Altitude", &conf.Altitude}, {"Vario", &conf.Vario},
+		{"Throttle", &conf.Throttle}, {"Battery", &conf.Battery},
+	}
+	for i, t := range toggles {
+		if i%2 == 1 {
+			imgui.SameLine()
+		}
+		imgui.Checkbox(t.name, t.v)
+	}
+}
+
+func minF32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxF32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
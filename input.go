@@ -0,0 +1,72 @@
+package main
+
+import (
+	"time"
+
+	g "github.com/AllenDang/giu"
+	"github.com/AllenDang/imgui-go"
+	plane_physics "github.com/cowsed/GoFly/Physics"
+)
+
+//How fast (per second) a keyboard axis moves toward full deflection or back to center
+const keyboardAxisRate = 4.0
+
+//How fast (per second) throttle and flaps move while their keys are held
+const keyboardLeverRate = 0.5
+
+var lastInputTime = time.Now()
+
+//UpdateControls moves the control channels toward the positions given by the held keys
+//Stick axes spring back to center when released, throttle and flaps stay where they are left
+func UpdateControls(c *plane_physics.ControlState) {
+	dt := time.Since(lastInputTime).Seconds()
+	lastInputTime = time.Now()
+
+	axisStep := keyboardAxisRate * dt
+	c.Elevator = slewToward(c.Elevator, keyAxis(g.KeyDown, g.KeyUp), axisStep)
+	c.Aileron = slewToward(c.Aileron, keyAxis(g.KeyRight, g.KeyLeft), axisStep)
+	c.Rudder = slewToward(c.Rudder, keyAxis(g.KeyE, g.KeyQ), axisStep)
+
+	leverStep := keyboardLeverRate * dt
+	c.Throttle += keyAxis(g.KeyPageUp, g.KeyPageDown) * leverStep
+	c.Flaps += keyAxis(g.KeyEnd, g.KeyHome) * leverStep
+	c.Clamp()
+}
+
+//keyAxis is 1 while positive is held, -1 while negative is held and 0 otherwise
+//Keys typed into a GUI field are the field's, they count as released
+func keyAxis(positive, negative g.Key) float64 {
+	if imgui.CurrentIO().WantTextInput() {
+		return 0
+	}
+	v := 0.0
+	if g.IsKeyDown(positive) {
+		v++
+	}
+	if g.IsKeyDown(negative) {
+		v--
+	}
+	return v
+}
+
+//slewToward moves v toward target by no more than maxStep
+func slewToward(v, target, maxStep float64) float64 {
+	if v < target {
+		return minF(v+maxStep, target)
+	}
+	return maxF(v-maxStep, target)
+}
+
+func minF(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxF(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	g "github.com/AllenDang/giu"
+	"github.com/AllenDang/imgui-go"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
 )
@@ -13,7 +14,6 @@ var Simulation *Sim
 var frame = 0
 var Paused = true
 var fullWindow = false
-var followModel = false
 
 func refresh() {
 	ticker := time.NewTicker(time.Millisecond * 16)
@@ -55,6 +55,8 @@ func loop() {
 
 	MakeUI()
 
+	UpdateControls(&Simulation.physContext.Controls)
+
 	Simulation.DoPhysics(Paused)
 
 	if !Paused {
@@ -65,7 +67,10 @@ func loop() {
 		Paused = !Paused
 	}
 	if g.IsKeyPressed(g.KeyR) {
-		Simulation.physContext.ResetPhysics()
+		Simulation.Relaunch()
+	}
+	if g.IsKeyPressed(g.KeyC) && !imgui.CurrentIO().WantTextInput() {
+		Simulation.CycleCamera()
 	}
 	if g.IsKeyPressed(g.KeyF) {
 		fullWindow = !fullWindow
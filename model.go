@@ -1,6 +1,8 @@
 package main
 
 import (
+	"log"
+
 	graphics "github.com/cowsed/GoFly/Graphics"
 	physics "github.com/cowsed/GoFly/Physics"
 	"github.com/go-gl/mathgl/mgl32"
@@ -10,6 +12,18 @@ import (
 type Model struct {
 	physObj *physics.PhysicsObject
 	model3d *graphics.Model
+	scale   mgl64.Vec3 //stretches the model to the size of its physics shape
+
+	drawnState physics.BodyState //where ApplyPhysics last put it
+
+	surfaces []controlSurface
+}
+
+//controlSurface is an animation from the airplane file bound to the parts of the model it moves
+type controlSurface struct {
+	spec        *physics.AnimationSpec
+	parts       []int
+	pivot, axis mgl32.Vec3
 }
 
 func LoadModel(fname string, physObj *physics.PhysicsObject) *Model {
@@ -18,17 +32,72 @@ func LoadModel(fname string, physObj *physics.PhysicsObject) *Model {
 	return &Model{
 		physObj: physObj,
 		model3d: gfxMod,
+		scale:   mgl64.Vec3{1, 1, 1},
 	}
 }
 
+//BindAnimations finds the parts each of the airplane's control surface animations move
+func (m *Model) BindAnimations(a *physics.Airplane) {
+	m.surfaces = nil
+	for i := range a.Animations {
+		spec := &a.Animations[i]
+		if spec.Type != physics.AnimationControlSurface {
+			continue
+		}
+		pivot, axis, ok := spec.Hinge()
+		parts := m.model3d.PartsNamed(spec.Object.Name)
+		if !ok || len(parts) == 0 {
+			log.Printf("Can't animate %q: no hinge line or no part with that name", spec.Object.Name)
+			continue
+		}
+		m.surfaces = append(m.surfaces, controlSurface{spec, parts, V64toV32(pivot), V64toV32(axis)})
+	}
+}
+
+//Animate turns the control surfaces about their hinges for the current control inputs
+func (m *Model) Animate(c physics.ControlState) {
+	for _, s := range m.surfaces {
+		angle := float32(s.spec.Angle(c))
+		transform := mgl32.Translate3D(s.pivot[0], s.pivot[1], s.pivot[2]).
+			Mul4(mgl32.HomogRotate3D(angle, s.axis)).
+			Mul4(mgl32.Translate3D(-s.pivot[0], -s.pivot[1], -s.pivot[2]))
+		for _, part := range s.parts {
+			m.model3d.SetPartTransform(part, transform)
+		}
+	}
+}
+
+//ShapeFromBounds is a box around the whole model, for colliding with other bodies
+func ShapeFromBounds(m *Model) physics.Shape {
+	min, max := m.model3d.Bounds()
+	lo, hi := mgl64.Vec3(V32toV64(min)), mgl64.Vec3(V32toV64(max))
+	return physics.Box{HalfExtents: hi.Sub(lo).Mul(0.5), Offset: hi.Add(lo).Mul(0.5)}
+}
+
+//TerrainFromModel makes the scenery model solid for the physics
+func TerrainFromModel(m *Model) *physics.Terrain {
+	tris32 := m.model3d.Triangles()
+	tris := make([][3]mgl64.Vec3, len(tris32))
+	for i := range tris32 {
+		for j := range tris32[i] {
+			tris[i][j] = V32toV64(tris32[i][j])
+		}
+	}
+	return physics.NewTerrain(tris)
+}
+
 func Quat64toQuat32(q mgl64.Quat) mgl32.Quat {
 	return mgl32.Quat{
 		W: float32(q.W),
 		V: V64toV32(q.V),
 	}
 }
-func (m *Model) ApplyPhysics() {
-	mat := m.physObj.Orientation.Mat4().Mul4(mgl64.Translate3D(m.physObj.Position[0], m.physObj.Position[1], m.physObj.Position[2]))
+
+//ApplyPhysics places the 3d model at a (possibly interpolated) physics state
+func (m *Model) ApplyPhysics(state physics.BodyState) {
+	m.drawnState = state
+	mat := mgl64.Translate3D(state.Position[0], state.Position[1], state.Position[2]).Mul4(state.Orientation.Mat4())
+	mat = mat.Mul4(mgl64.Scale3D(m.scale[0], m.scale[1], m.scale[2]))
 	m.model3d.ModelMatrix = M64toM32(mat)
 }
 
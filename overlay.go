@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/AllenDang/imgui-go"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//WorldToImage projects a world space point onto the render image drawn between min and max
+//Returns false for points behind the camera
+func WorldToImage(p mgl64.Vec3, min, max imgui.Vec2) (imgui.Vec2, bool) {
+	gfx := Simulation.gfxContext
+	clip := gfx.Projection.Mul4(gfx.View).Mul4x1(mgl32.Vec3(V64toV32(p)).Vec4(1))
+	if clip[3] <= 0 {
+		return imgui.Vec2{}, false
+	}
+	ndc := clip.Vec3().Mul(1 / clip[3])
+	return imgui.Vec2{
+		X: min.X + (ndc[0]+1)/2*(max.X-min.X),
+		Y: min.Y + (1-ndc[1])/2*(max.Y-min.Y),
+	}, true
+}
+
+//DrawThermalOverlay marks each thermal's leaning column and its core at the aircraft's height over the last drawn image
+func DrawThermalOverlay(s *Sim) {
+	min, max := imgui.GetItemRectMin(), imgui.GetItemRectMax()
+	draw := imgui.GetWindowDrawList()
+	ts := s.thermals
+	t := s.physContext.SumDT
+	planeHeight := s.physContext.Model.Position[1]
+
+	const segments = 8
+	for i := range ts.Active {
+		th := &ts.Active[i]
+		env := float32(th.Envelope(t))
+		if env == 0 {
+			continue
+		}
+		color := imgui.Vec4{X: 1, Y: 0.6, Z: 0.1, W: 0.3 + 0.7*env}
+
+		//The column from the ground to the ceiling
+		last, lastOK := WorldToImage(ts.CoreAt(th, 0, t), min, max)
+		for j := 1; j <= segments; j++ {
+			h := ts.Ceiling * float64(j) / segments
+			next, ok := WorldToImage(ts.CoreAt(th, h, t), min, max)
+			if ok && lastOK {
+				draw.AddLine(last, next, color, 2)
+			}
+			last, lastOK = next, ok
+		}
+
+		//Core ring where the aircraft is flying
+		if planeHeight <= 0 || planeHeight >= ts.Ceiling {
+			continue
+		}
+		core := ts.CoreAt(th, planeHeight, t)
+		center, ok := WorldToImage(core, min, max)
+		edge, edgeOK := WorldToImage(core.Add(mgl64.Vec3{th.Radius, 0, 0}), min, max)
+		if ok && edgeOK {
+			radius := mgl32.Vec2{edge.X - center.X, edge.Y - center.Y}.Len()
+			draw.AddCircle(center, radius, color, 24, 2)
+		}
+	}
+}
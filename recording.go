@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/AllenDang/imgui-go"
+	plane_physics "github.com/cowsed/GoFly/Physics"
+)
+
+//Directory flights are saved to and loaded from
+const recordingsDir = "Recordings"
+
+//Playback shows a recorded flight instead of simulating
+type Playback struct {
+	Log     *plane_physics.FlightLog
+	Name    string
+	Time    float64 //s into the flight
+	Speed   float64 //recorded seconds per real second
+	Playing bool
+}
+
+//Advance moves the playback on by elapsed seconds of real time
+func (p *Playback) Advance(elapsed float64) {
+	if !p.Playing {
+		return
+	}
+	p.Time += elapsed * p.Speed
+	if p.Time >= p.Log.Duration() {
+		p.Time = p.Log.Duration()
+		p.Playing = false
+	}
+}
+
+//syncSettings copies what the GUI changes while flying back into the settings, so a flight can be set up again from them
+func (s *Sim) syncSettings() {
+	ps := s.physContext
+	Settings.Seed = s.seed
+	Settings.PhysicsRate = ps.PhysicsRate
+	Settings.Integrator = ps.Integrator.Name()
+	Settings.FieldElevation = ps.Atmosphere.FieldElevation
+	Settings.TemperatureOffset = ps.Atmosphere.TemperatureOffset
+	Settings.PressureOffset = ps.Atmosphere.PressureOffset
+	Settings.WindSpeed = s.wind.Speed
+	Settings.WindDirection = s.wind.Direction * 180 / math.Pi
+	Settings.WindRoughness = s.wind.Roughness
+	Settings.TurbulenceModel = s.turbulence.Model
+	Settings.TurbulenceW20 = s.turbulence.W20
+	Settings.ThermalCount = s.thermals.Count
+	Settings.ThermalStrength = s.thermals.Strength
+	Settings.ThermalRadius = s.thermals.Radius
+}
+
+//restartWorld puts the clock, weather and airplane back to how the settings start them
+//Everything random is seeded, so the same settings and inputs always give the same flight
+func (s *Sim) restartWorld() {
+	ps := s.physContext
+	ps.SumDT = 0
+	ps.PhysicsRate = Settings.PhysicsRate
+	integrator, err := plane_physics.NewIntegrator(Settings.Integrator)
+	check(err)
+	ps.Integrator = integrator
+	ps.Atmosphere = plane_physics.Atmosphere{
+		FieldElevation:    Settings.FieldElevation,
+		TemperatureOffset: Settings.TemperatureOffset,
+		PressureOffset:    Settings.PressureOffset,
+	}
+	s.seed = Settings.Seed
+	s.MakeWind()
+	ps.Controls = plane_physics.ControlState{}
+	s.Relaunch()
+}
+
+//WorldLocked is true while a flight is recorded or re-simulated, the weather and integrator have to stay as they started
+//Only the controls and gusts are logged, so any other change would make the flight impossible to repeat
+func (s *Sim) WorldLocked() bool {
+	return s.recorder != nil || s.resim != nil
+}
+
+//StartRecording relaunches and records the flight until the next relaunch or StopRecording
+func (s *Sim) StartRecording() {
+	s.StopPlayback()
+	s.syncSettings()
+	s.restartWorld()
+	settings, err := json.Marshal(Settings)
+	check(err)
+	s.recorder = plane_physics.NewRecorder(s.physContext, plane_physics.FlightLogHeader{
+		Aircraft: Settings.AircraftPath,
+		Seed:     s.seed,
+		Settings: settings,
+	})
+	s.physContext.AfterStep.Add("recorder", s.recorder.AfterStep)
+	s.replayStatus = ""
+}
+
+//StopRecording saves the flight being recorded
+func (s *Sim) StopRecording() {
+	if s.recorder == nil {
+		return
+	}
+	fl := s.recorder.Log
+	s.recorder = nil
+	s.physContext.AfterStep.Remove("recorder")
+
+	check(os.MkdirAll(recordingsDir, 0755))
+	name := filepath.Join(recordingsDir, time.Now().Format("2006-01-02_15-04-05")+".gfl")
+	f, err := os.Create(name)
+	check(err)
+	defer f.Close()
+	check(fl.Write(f))
+	s.replayStatus = fmt.Sprintf("Saved %.1f s to %s", fl.Duration(), name)
+}
+
+//LoadFlight switches to playing back a saved flight
+func (s *Sim) LoadFlight(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fl, err := plane_physics.ReadFlightLog(f)
+	if err != nil {
+		return err
+	}
+	if fl.Header.Aircraft != Settings.AircraftPath {
+		return fmt.Errorf("%s was flown with %s, this is %s", name, fl.Header.Aircraft, Settings.AircraftPath)
+	}
+	if fl.Header.Bodies != len(s.physContext.Bodies)-s.staticBodies() {
+		return fmt.Errorf("%s has %d moving bodies, this scene has %d", name, fl.Header.Bodies, len(s.physContext.Bodies)-s.staticBodies())
+	}
+	s.StopRecording()
+	s.StopResimulation()
+	s.playback = &Playback{Log: fl, Name: filepath.Base(name), Speed: 1, Playing: true}
+	return nil
+}
+
+func (s *Sim) staticBodies() int {
+	n := 0
+	for _, b := range s.physContext.Bodies {
+		if b.Static {
+			n++
+		}
+	}
+	return n
+}
+
+//StopPlayback goes back to flying
+func (s *Sim) StopPlayback() {
+	if s.playback == nil {
+		return
+	}
+	s.playback = nil
+	s.Relaunch()
+}
+
+//Resimulate flies the recorded inputs through the physics again and checks the trajectory matches
+//The flight has to be of the airplane and scene loaded now, its weather and other settings are taken from the log
+func (s *Sim) Resimulate(fl *plane_physics.FlightLog) error {
+	conf := DefaultConfig
+	if err := json.Unmarshal(fl.Header.Settings, &conf); err != nil {
+		return fmt.Errorf("flight settings: %v", err)
+	}
+	if conf.AircraftPath != Settings.AircraftPath || conf.AircraftConfig != Settings.AircraftConfig ||
+		conf.SceneryPath != Settings.SceneryPath || !reflect.DeepEqual(conf.Props, Settings.Props) {
+		return fmt.Errorf("flown with %s in %s, restart with its airplane and scene to re-simulate", conf.AircraftPath, conf.SceneryPath)
+	}
+	s.playback = nil
+	s.StopRecording()
+	Settings = conf
+	s.restartWorld()
+	s.resim = plane_physics.NewResimulation(fl, func(g plane_physics.Gust) {
+		s.gusts.Add(g)
+	})
+	s.physContext.BeforeStep.Add("resim", s.resim.BeforeStep)
+	s.physContext.AfterStep.Add("resim", s.resim.AfterStep)
+	s.replayStatus = "Re-simulating..."
+	return nil
+}
+
+//checkResimulation reports and unhooks a finished re-simulation
+func (s *Sim) checkResimulation() {
+	if s.resim == nil || !s.resim.Done() {
+		return
+	}
+	if s.resim.Diverged < 0 {
+		s.replayStatus = fmt.Sprintf("Re-simulated %d steps, identical to the recording", s.resim.Frame)
+	} else {
+		s.replayStatus = fmt.Sprintf("Diverged at step %d (%.2f s), off by up to %.3g m",
+			s.resim.Diverged, float64(s.resim.Diverged)/s.resim.Log.Header.PhysicsRate, s.resim.MaxError)
+	}
+	s.StopResimulation()
+	Paused = true
+}
+
+func (s *Sim) StopResimulation() {
+	if s.resim == nil {
+		return
+	}
+	s.resim = nil
+	s.physContext.BeforeStep.Remove("resim")
+	s.physContext.AfterStep.Remove("resim")
+}
+
+//savedFlights lists the flight logs in the recordings directory, newest first
+func savedFlights() []string {
+	entries, err := os.ReadDir(recordingsDir)
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) == ".gfl" {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names
+}
+
+//RecordingControls records, loads and plays back flights
+func RecordingControls(s *Sim) {
+	imgui.Separator()
+	switch {
+	case s.recorder != nil:
+		imgui.Text(fmt.Sprintf("Recording %.1f s", s.recorder.Log.Duration()))
+		if imgui.Button("Stop recording") {
+			s.StopRecording()
+		}
+	case s.resim != nil:
+		imgui.Text(fmt.Sprintf("Re-simulating %.1f / %.1f s", float64(s.resim.Frame)/s.resim.Log.Header.PhysicsRate, s.resim.Log.Duration()))
+		if imgui.Button("Stop re-simulating") {
+			s.StopResimulation()
+			s.replayStatus = ""
+		}
+	default:
+		if imgui.Button("Record") {
+			s.StartRecording()
+		}
+	}
+
+	if imgui.BeginCombo("Load flight", "") {
+		for _, name := range savedFlights() {
+			if imgui.SelectableV(name, false, 0, imgui.Vec2{}) {
+				if err := s.LoadFlight(filepath.Join(recordingsDir, name)); err != nil {
+					s.replayStatus = err.Error()
+				}
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	if p := s.playback; p != nil {
+		imgui.Text(fmt.Sprintf("Playing %s", p.Name))
+		t := float32(p.Time)
+		if imgui.SliderFloatV("Time (s)", &t, 0, float32(p.Log.Duration()), "%.2f", 1) {
+			p.Time = float64(t)
+		}
+		label := "Play"
+		if p.Playing {
+			label = "Pause"
+		}
+		if imgui.Button(label) {
+			if !p.Playing && p.Time >= p.Log.Duration() {
+				p.Time = 0
+			}
+			p.Playing = !p.Playing
+		}
+		imgui.SameLine()
+		if imgui.Button("Re-simulate") {
+			if err := s.Resimulate(p.Log); err != nil {
+				s.replayStatus = err.Error()
+			}
+		}
+		imgui.SameLine()
+		if imgui.Button("Back to flying") {
+			s.StopPlayback()
+		}
+		if s.playback != nil {
+			SliderFloat64("Playback speed", &p.Speed, 0.1, 4)
+		}
+	}
+	if s.replayStatus != "" {
+		imgui.Text(s.replayStatus)
+	}
+}
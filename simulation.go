@@ -2,18 +2,42 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	graphics "github.com/cowsed/GoFly/Graphics"
 	plane_physics "github.com/cowsed/GoFly/Physics"
 	"github.com/go-gl/mathgl/mgl32"
+	"github.com/go-gl/mathgl/mgl64"
 )
 
 type Sim struct {
 	mod   *Model
 	scene *Model
+	props []*Model
 
 	gfxContext  *graphics.GraphicsContext
 	physContext *plane_physics.PhysicsSim
+	camera      *CameraController
+	viewport    Viewport
+
+	lastPhysicsTime time.Time
+	lastDrawTime    time.Time
+
+	seed       int64
+	wind       *plane_physics.BoundaryLayer
+	gusts      *plane_physics.Gusts
+	turbulence *plane_physics.Turbulence
+	thermals   *plane_physics.Thermals
+	ridge      *plane_physics.RidgeLift
+
+	recorder     *plane_physics.Recorder
+	playback     *Playback
+	resim        *plane_physics.Resimulation
+	replayStatus string
+
+	telemetry       *Telemetry
+	telemetryStatus string
 }
 
 func NewSim() *Sim {
@@ -22,29 +46,214 @@ func NewSim() *Sim {
 
 	s.gfxContext = graphics.InitGraphicsContext(Settings.EnvironmentPath, Settings.SceneryPath, Settings.CameraFOV)
 	s.physContext = plane_physics.InitPhysicsContext()
-	s.mod = LoadModel(Settings.ModelPath, s.physContext.Model)
+	s.physContext.PhysicsRate = Settings.PhysicsRate
+	s.physContext.MaxCatchUp = Settings.MaxCatchUp
+	s.physContext.TimeScale = Settings.TimeScale
+	integrator, err := plane_physics.NewIntegrator(Settings.Integrator)
+	check(err)
+	s.physContext.Integrator = integrator
+	s.physContext.Atmosphere = plane_physics.Atmosphere{
+		FieldElevation:    Settings.FieldElevation,
+		TemperatureOffset: Settings.TemperatureOffset,
+		PressureOffset:    Settings.PressureOffset,
+	}
+	s.seed = Settings.Seed
+	if s.seed == 0 {
+		s.seed = time.Now().UnixNano()
+	}
+	s.MakeWind()
+	modelPath := Settings.ModelPath
+	if Settings.AircraftPath != "" {
+		airplane, err := plane_physics.LoadAirplane(Settings.AircraftPath)
+		check(err)
+		check(s.physContext.LoadAirplane(airplane, Settings.AircraftConfig))
+		if p := airplane.ModelPath(); p != "" {
+			modelPath = p
+		}
+	}
+	s.mod = LoadModel(modelPath, s.physContext.Model)
+	if s.physContext.Airplane != nil {
+		s.mod.BindAnimations(s.physContext.Airplane)
+	}
 	s.scene = LoadModel(Settings.SceneryPath, nil)
 	s.scene.model3d.ModelMatrix = mgl32.Ident4()
+	s.physContext.Terrain = TerrainFromModel(s.scene)
+	s.ridge.Terrain = s.physContext.Terrain
+	s.physContext.Model.Shape = ShapeFromBounds(s.mod)
 	s.gfxContext.Mod = s.mod.model3d
 	s.gfxContext.Scene = s.scene.model3d
+	for _, pc := range Settings.Props {
+		s.AddProp(pc)
+	}
+
+	pilot := mgl64.Vec3(Settings.PilotPosition)
+	if h, _, ok := s.physContext.Terrain.Height(pilot[0], pilot[2]); ok {
+		pilot[1] += h
+	}
+	s.camera = NewCameraController(Settings.CameraMode, float64(Settings.CameraFOV), Settings.CameraAutoZoom, pilot)
+	s.physContext.Pilot = pilot
 
 	fmt.Println("SCENE", s.scene.model3d)
+	s.Relaunch()
+	s.telemetry = NewTelemetry()
+	s.physContext.AfterStep.Add("telemetry", func(ps *plane_physics.PhysicsSim) {
+		s.telemetry.Sample(&s)
+	})
+	s.lastPhysicsTime = time.Now()
 	return &s
 }
-func (s *Sim) DoPhysics(paused bool) {
-	s.physContext.DoPhysics(paused)
 
+//AddProp places a scenery object on the ground and gives it a body
+func (s *Sim) AddProp(pc PropConfig) {
+	size := mgl64.Vec3(pc.Size)
+	shape, err := plane_physics.NewShape(pc.Shape, size)
+	if err != nil {
+		check(fmt.Errorf("prop %q: %v", pc.Name, err))
+	}
+	pos := mgl64.Vec3(pc.Position)
+	if h, _, ok := s.physContext.Terrain.Height(pos[0], pos[2]); ok {
+		pos[1] += h
+	}
+	heading := mgl64.QuatRotate(pc.Heading*math.Pi/180, mgl64.Vec3{0, 1, 0})
+	body := plane_physics.NewBody(shape, pc.Mass, pos, heading)
+	s.physContext.AddBody(body)
+
+	path := pc.ModelPath
+	if path == "" {
+		path = propModels[pc.Shape]
+	}
+	prop := LoadModel(path, body)
+	if _, ok := shape.(plane_physics.Sphere); ok {
+		size = mgl64.Vec3{size[0], size[0], size[0]}
+	}
+	prop.scale = size
+	prop.ApplyPhysics(body.State())
+	s.props = append(s.props, prop)
+	s.gfxContext.Bodies = append(s.gfxContext.Bodies, prop.model3d)
+}
+
+//Unit sized models props are drawn with when they don't have their own
+var propModels = map[string]string{
+	plane_physics.ShapeBox:    "Assets/Planes/cube.ac",
+	plane_physics.ShapeSphere: "Assets/Models/sphere.ac",
+}
+
+//Relaunch starts a new flight from the chosen launch preset, into the wind if there is any
+//Relaunching ends any recording or re-simulation
+func (s *Sim) Relaunch() {
+	s.StopRecording()
+	s.StopResimulation()
+	heading := 0.0
+	if s.wind.Speed > 0 {
+		heading = math.Atan2(-math.Cos(s.wind.Direction), -math.Sin(s.wind.Direction))
+	}
+	s.physContext.Launch(s.physContext.LaunchPreset(Settings.LaunchPreset), heading)
+}
+
+//MakeWind sets up the steady wind, gusts and turbulence from the settings
+func (s *Sim) MakeWind() {
+	direction := Settings.WindDirection * math.Pi / 180
+	s.wind = &plane_physics.BoundaryLayer{
+		SteadyWind: plane_physics.SteadyWind{Speed: Settings.WindSpeed, Direction: direction},
+		RefHeight:  10,
+		Roughness:  Settings.WindRoughness,
+	}
+	s.gusts = &plane_physics.Gusts{}
+	s.turbulence = &plane_physics.Turbulence{
+		Model:     Settings.TurbulenceModel,
+		W20:       Settings.TurbulenceW20,
+		Direction: direction,
+		Seed:      s.seed,
+	}
+	s.thermals = &plane_physics.Thermals{
+		Area:     Settings.ThermalArea,
+		Count:    Settings.ThermalCount,
+		Strength: Settings.ThermalStrength,
+		Radius:   Settings.ThermalRadius,
+		Lifetime: Settings.ThermalLifetime,
+		Ceiling:  Settings.ThermalCeiling,
+		Drift:    s.wind,
+		//Offset so the thermals don't share the turbulence's random numbers
+		Seed: s.seed + 1,
+	}
+	s.ridge = &plane_physics.RidgeLift{Wind: s.wind, Terrain: s.physContext.Terrain, DecayHeight: 30}
+	s.UpdateWindFields()
+}
+
+//UpdateWindFields picks which of the wind and lift sources are active
+func (s *Sim) UpdateWindFields() {
+	fields := plane_physics.WindFields{s.wind, s.gusts, s.turbulence, s.thermals}
+	if Settings.RidgeLift {
+		fields = append(fields, s.ridge)
+	}
+	s.physContext.Wind = fields
+}
+
+//AddGust starts a gust from the direction of the wind right now
+func (s *Sim) AddGust() {
+	peak := mgl64.Vec3{math.Cos(s.wind.Direction), 0, math.Sin(s.wind.Direction)}.Mul(Settings.GustSpeed)
+	gust := plane_physics.Gust{Start: s.physContext.SumDT, Duration: Settings.GustDuration, Peak: peak}
+	s.gusts.Add(gust)
+	if s.recorder != nil {
+		s.recorder.AddGust(gust)
+	}
+}
+
+//DoPhysics advances the physics by the real time since it was last called
+func (s *Sim) DoPhysics(paused bool) {
+	now := time.Now()
+	elapsed := now.Sub(s.lastPhysicsTime).Seconds()
+	s.lastPhysicsTime = now
+	if s.playback != nil {
+		s.playback.Advance(elapsed)
+		return
+	}
+	if paused {
+		return
+	}
+	s.physContext.Advance(elapsed)
+	s.checkResimulation()
 }
 
 func (s *Sim) Draw() {
-	if followModel {
-		s.gfxContext.Cam.Lookat = V64toV32(s.mod.physObj.Position)
+	if s.playback != nil {
+		s.drawPlayback()
+	} else {
+		s.mod.ApplyPhysics(s.physContext.Interpolated())
+		s.mod.Animate(s.physContext.Controls)
+		for _, p := range s.props {
+			if !p.physObj.Static {
+				p.ApplyPhysics(s.physContext.InterpolatedBody(p.physObj))
+			}
+		}
+	}
+	state := s.mod.drawnState
+	now := time.Now()
+	velocity := s.physContext.Model.Velocity()
+	if s.playback != nil {
+		velocity = mgl64.Vec3{}
 	}
-	s.mod.ApplyPhysics()
+	s.camera.Update(&s.gfxContext.Cam, state, velocity, now.Sub(s.lastDrawTime).Seconds())
+	s.lastDrawTime = now
 
-	s.gfxContext.BeginDraw(V64toV32(s.mod.physObj.Position))
+	s.gfxContext.BeginDraw(V64toV32(state.Position))
 	s.gfxContext.DrawModels()
 
 	s.gfxContext.EndDraw()
 
 }
+
+//drawPlayback places the airplane and moving props where the recording has them
+func (s *Sim) drawPlayback() {
+	p := s.playback
+	s.mod.ApplyPhysics(p.Log.StateAt(p.Time, 0))
+	frame, _ := p.Log.FrameAt(p.Time)
+	s.mod.Animate(p.Log.Frames[frame].Controls)
+	body := 1
+	for _, prop := range s.props {
+		if !prop.physObj.Static {
+			prop.ApplyPhysics(p.Log.StateAt(p.Time, body))
+			body++
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	g "github.com/AllenDang/giu"
+	"github.com/AllenDang/imgui-go"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//Directory exported telemetry is written to
+const telemetryDir = "Telemetry"
+
+//Seconds of telemetry kept, the longest window that can be shown
+const maxTelemetryHistory = 300.0
+
+//Samples per second of simulated time, the physics steps far more often than a plot can show
+const telemetryRate = 100.0
+
+//Signal is one value that can be plotted
+//Signals sharing a Plot are drawn on the same graph
+type Signal struct {
+	Name string
+	Plot string
+	Get  func(s *Sim) float64
+}
+
+var telemetrySignals = []Signal{
+	{"Altitude (m)", "Altitude (m)", func(s *Sim) float64 { return s.physContext.Model.Position[1] }},
+	{"Airspeed (m/s)", "Airspeed (m/s)", func(s *Sim) float64 { return s.physContext.Aero.Airspeed }},
+	{"Vertical speed (m/s)", "Vertical speed (m/s)", func(s *Sim) float64 { return s.physContext.Model.Velocity()[1] }},
+	{"Angle of attack (deg)", "Angle of attack (deg)", func(s *Sim) float64 { return mgl64.RadToDeg(s.physContext.Aero.Alpha) }},
+	{"Load factor (g)", "Load factor (g)", func(s *Sim) float64 { return s.physContext.LoadFactor() }},
+	{"Total energy (J)", "Total energy (J)", func(s *Sim) float64 { return s.physContext.TotalEnergy() }},
+	{"Elevator", "Controls", func(s *Sim) float64 { return s.physContext.Controls.Elevator }},
+	{"Aileron", "Controls", func(s *Sim) float64 { return s.physContext.Controls.Aileron }},
+	{"Rudder", "Controls", func(s *Sim) float64 { return s.physContext.Controls.Rudder }},
+	{"Throttle", "Controls", func(s *Sim) float64 { return s.physContext.Controls.Throttle }},
+	{"Flaps", "Controls", func(s *Sim) float64 { return s.physContext.Controls.Flaps }},
+}
+
+//Telemetry keeps a rolling history of every signal
+type Telemetry struct {
+	Enabled []bool
+	Window  float64 //s of history shown
+	Paused  bool    //stops recording so the plots can be inspected
+
+	times  []float64
+	values [][]float64 //per signal, the same length as times
+}
+
+func NewTelemetry() *Telemetry {
+	t := Telemetry{
+		Enabled: make([]bool, len(telemetrySignals)),
+		Window:  20,
+		values:  make([][]float64, len(telemetrySignals)),
+	}
+	//Altitude, airspeed and vertical speed to start with
+	for i := 0; i < 3; i++ {
+		t.Enabled[i] = true
+	}
+	return &t
+}
+
+//Sample records every signal at the current simulated time
+//The sim calls it after every physics step, only steps telemetryRate apart are kept
+func (t *Telemetry) Sample(s *Sim) {
+	if t.Paused {
+		return
+	}
+	now := s.physContext.SumDT
+	if n := len(t.times); n > 0 {
+		if now < t.times[n-1] {
+			//The clock was restarted, the old history no longer lines up
+			t.Clear()
+		} else if now-t.times[n-1] < 1/telemetryRate-1e-9 {
+			//Too soon after the last sample, the small margin keeps rounding in SumDT from skipping a step's worth
+			return
+		}
+	}
+	t.times = append(t.times, now)
+	for i, sig := range telemetrySignals {
+		t.values[i] = append(t.values[i], sig.Get(s))
+	}
+
+	//Drop old samples in batches so the slices aren't copied every sample
+	if t.times[0] < now-2*maxTelemetryHistory {
+		start := sort.SearchFloat64s(t.times, now-maxTelemetryHistory)
+		t.times = append([]float64{}, t.times[start:]...)
+		for i := range t.values {
+			t.values[i] = append([]float64{}, t.values[i][start:]...)
+		}
+	}
+}
+
+func (t *Telemetry) Clear() {
+	t.times = t.times[:0]
+	for i := range t.values {
+		t.values[i] = t.values[i][:0]
+	}
+}
+
+//visible is the range of samples inside the time window
+func (t *Telemetry) visible() (int, int) {
+	if len(t.times) == 0 {
+		return 0, 0
+	}
+	end := len(t.times)
+	start := sort.SearchFloat64s(t.times, t.times[end-1]-t.Window)
+	return start, end
+}
+
+//Export writes the visible samples of the enabled signals to a CSV file and returns its name
+func (t *Telemetry) Export() (string, error) {
+	if err := os.MkdirAll(telemetryDir, 0755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(telemetryDir, time.Now().Format("2006-01-02_15-04-05")+".csv")
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"Time (s)"}
+	for i, sig := range telemetrySignals {
+		if t.Enabled[i] {
+			header = append(header, sig.Name)
+		}
+	}
+	w.Write(header)
+	start, end := t.visible()
+	for j := start; j < end; j++ {
+		row := []string{strconv.FormatFloat(t.times[j], 'g', -1, 64)}
+		for i := range telemetrySignals {
+			if t.Enabled[i] {
+				row = append(row, strconv.FormatFloat(t.values[i][j], 'g', -1, 64))
+			}
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return name, w.Error()
+}
+
+//TelemetryPanel plots the enabled signals over the time window
+func TelemetryPanel(s *Sim) {
+	t := s.telemetry
+	imgui.Separator()
+	if !imgui.TreeNode("Telemetry") {
+		return
+	}
+	defer imgui.TreePop()
+
+	for i, sig := range telemetrySignals {
+		if i%2 == 1 {
+			imgui.SameLine()
+		}
+		imgui.Checkbox(sig.Name, &t.Enabled[i])
+	}
+	SliderFloat64("Window (s)", &t.Window, 2, maxTelemetryHistory)
+	imgui.Checkbox("Pause plots", &t.Paused)
+	imgui.SameLine()
+	if imgui.Button("Export visible") {
+		name, err := t.Export()
+		s.telemetryStatus = "Exported " + name
+		if err != nil {
+			s.telemetryStatus = fmt.Sprintf("Export failed: %v", err)
+		}
+	}
+	if s.telemetryStatus != "" {
+		imgui.Text(s.telemetryStatus)
+	}
+
+	start, end := t.visible()
+	if start == end {
+		return
+	}
+	times := t.times[start:end]
+	now := times[len(times)-1]
+
+	//One graph per plot name, in the order the signals are listed
+	plots := map[string][]g.PlotWidget{}
+	order := []string{}
+	for i, sig := range telemetrySignals {
+		if !t.Enabled[i] {
+			continue
+		}
+		if _, ok := plots[sig.Plot]; !ok {
+			order = append(order, sig.Plot)
+		}
+		plots[sig.Plot] = append(plots[sig.Plot], g.PlotLineXY(sig.Name, times, t.values[i][start:end]))
+	}
+	for _, name := range order {
+		g.Plot(name).
+			AxisLimits(now-t.Window, now, 0, 1, g.ConditionAlways).
+			YAxeFlags(g.PlotAxisFlagsAutoFit, g.PlotAxisFlagsNoGridLines, g.PlotAxisFlagsNoGridLines).
+			Flags(g.PlotFlagsNoMenus|g.PlotFlagsNoBoxSelect).
+			Size(-1, 150).
+			Plots(plots[name]...).
+			Build()
+	}
+}
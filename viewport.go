@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	g "github.com/AllenDang/giu"
+	"github.com/AllenDang/imgui-go"
+	"github.com/go-gl/mathgl/mgl64"
+)
+
+//imgui mouse buttons
+const (
+	mouseLeft   = 0
+	mouseRight  = 1
+	mouseMiddle = 2
+)
+
+const (
+	orbitSensitivity = 0.005 //rad per pixel dragged
+	zoomStep         = 1.15  //distance or FOV factor per scroll notch
+	flySpeed         = 10.0  //m/s with WASD, four times that with shift
+)
+
+//Viewport tracks mouse and keyboard input over the render
+type Viewport struct {
+	focused    bool //the render was clicked last, WASD only fly the camera while it is
+	dragging   bool //a button pressed over the render is still held, it keeps dragging outside
+	dragButton int
+	last       time.Time
+}
+
+//ViewportInput moves the camera with the mouse and keyboard over the last drawn image
+//Presses that start over a widget never reach the camera, and the keys only fly it once the render has been clicked
+func ViewportInput(s *Sim) {
+	v := &s.viewport
+	c := s.camera
+	io := imgui.CurrentIO()
+	hovered := imgui.IsItemHovered()
+	min, max := imgui.GetItemRectMin(), imgui.GetItemRectMax()
+
+	now := time.Now()
+	dt := math.Min(now.Sub(v.last).Seconds(), 0.1)
+	v.last = now
+
+	for _, b := range []int{mouseLeft, mouseRight, mouseMiddle} {
+		if imgui.IsMouseClicked(b) {
+			v.focused = hovered
+			if hovered && b != mouseRight {
+				v.dragging, v.dragButton = true, b
+			}
+		}
+	}
+	if v.dragging && !imgui.IsMouseDown(v.dragButton) {
+		v.dragging = false
+	}
+
+	if delta := io.GetMouseDelta(); v.dragging && (delta.X != 0 || delta.Y != 0) {
+		if c.Mode != CameraOrbit {
+			//Grabbing the view lets go of the airplane, the saved mode stays the one the user picked
+			s.setCameraMode(CameraOrbit)
+		}
+		switch v.dragButton {
+		case mouseLeft:
+			c.Yaw -= float64(delta.X) * orbitSensitivity
+			c.Pitch += float64(delta.Y) * orbitSensitivity
+		case mouseMiddle:
+			//Move the target so the point under the mouse stays under it
+			_, right, up := c.axes()
+			perPixel := 2 * c.Distance * math.Tan(mgl64.DegToRad(c.FOV)/2) / float64(max.Y-min.Y)
+			c.Target = c.Target.Add(right.Mul(-float64(delta.X) * perPixel)).Add(up.Mul(float64(delta.Y) * perPixel))
+		}
+	}
+
+	if wheel := io.GetMouseWheelDelta(); hovered && wheel != 0 {
+		factor := math.Pow(zoomStep, float64(wheel))
+		if c.Mode == CameraOrbit {
+			c.Distance /= factor
+		} else {
+			c.FOV = mgl64.Clamp(c.FOV/factor, 5, 150)
+		}
+	}
+
+	if v.focused && !io.WantTextInput() && c.Mode == CameraOrbit {
+		forward, right, _ := c.axes()
+		move := forward.Mul(keyAxis(g.KeyW, g.KeyS)).Add(right.Mul(keyAxis(g.KeyD, g.KeyA)))
+		speed := flySpeed
+		if g.IsKeyDown(g.KeyLeftShift) {
+			speed *= 4
+		}
+		c.Target = c.Target.Add(move.Mul(speed * dt))
+	}
+}
+
+//axes are the directions the orbit camera looks, its right and its up
+func (c *CameraController) axes() (forward, right, up mgl64.Vec3) {
+	forward = mgl64.Vec3{
+		math.Cos(c.Pitch) * math.Sin(c.Yaw),
+		math.Sin(c.Pitch),
+		math.Cos(c.Pitch) * math.Cos(c.Yaw),
+	}.Mul(-1)
+	right = forward.Cross(mgl64.Vec3{0, 1, 0}).Normalize()
+	up = right.Cross(forward)
+	return forward, right, up
+}